@@ -0,0 +1,249 @@
+package twtxt
+
+// The registry API serves plain text in the format of the twtxt registry
+// protocol, one user or tweet per line:
+//
+//	GET  /api/plain/users[?q=...]       <registered>\t<nick>\t<url>
+//	POST /api/plain/users?url=...&nickname=...
+//	GET  /api/plain/tweets[?q=...]      <nick>\t<url>\t<created>\t<text>
+//	GET  /api/plain/mentions?url=...    <nick>\t<url>\t<created>\t<text>
+//	GET  /api/plain/tags/:tag           <nick>\t<url>\t<created>\t<text>
+//
+// All of them accept a page parameter, starting at 1.
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+)
+
+// registryPageSize is the number of users or tweets returned per page by the
+// registry API
+const registryPageSize = 20
+
+func apiPage(r *http.Request) int {
+	page, err := strconv.Atoi(r.FormValue("page"))
+	if err != nil || page < 1 {
+		return 1
+	}
+	return page
+}
+
+func paginate(n, page int) (int, int) {
+	start := (page - 1) * registryPageSize
+	if start > n {
+		start = n
+	}
+	end := start + registryPageSize
+	if end > n {
+		end = n
+	}
+	return start, end
+}
+
+// mentionsURL reports whether text mentions the feed at the normalized url
+func mentionsURL(text, url string) bool {
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		if NormalizeURL(match[2]) == url {
+			return true
+		}
+	}
+	return false
+}
+
+func writePlain(w http.ResponseWriter, status int, body string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprint(w, body)
+}
+
+// registryTweets holds the tweets last served by the registry API along
+// with the versions of the local feeds and the cache they were merged from
+var registryTweets struct {
+	sync.Mutex
+
+	feedsVersion uint64
+	cacheVersion uint64
+	tweets       Tweets
+}
+
+// allTweets returns the tweets of local feeds and of the cache for the
+// registry API, newest first. They are only merged again when either has
+// changed, and must not be modified.
+func (s *Server) allTweets() (Tweets, error) {
+	registryTweets.Lock()
+	defer registryTweets.Unlock()
+
+	cache, cacheVersion, err := loadSharedCache(s.config.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	feedsVersion := atomic.LoadUint64(&feedsVersion)
+	if registryTweets.tweets != nil &&
+		registryTweets.feedsVersion == feedsVersion &&
+		registryTweets.cacheVersion == cacheVersion {
+		return registryTweets.tweets, nil
+	}
+
+	tweets, err := GetAllTweets(s.config)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var all Tweets
+	for _, tweet := range append(tweets, cache.GetAll()...) {
+		if !seen[tweet.Hash()] {
+			seen[tweet.Hash()] = true
+			all = append(all, tweet)
+		}
+	}
+
+	sort.Sort(sort.Reverse(all))
+
+	registryTweets.feedsVersion = feedsVersion
+	registryTweets.cacheVersion = cacheVersion
+	registryTweets.tweets = all
+
+	return all, nil
+}
+
+// serveRegistryTweets writes the tweets matching filter in the format of the
+// registry API
+func (s *Server) serveRegistryTweets(w http.ResponseWriter, r *http.Request, filter func(tweet Tweet) bool) {
+	tweets, err := s.allTweets()
+	if err != nil {
+		log.WithError(err).Error("error loading tweets for registry api")
+		writePlain(w, http.StatusInternalServerError, "Internal Server Error\n")
+		return
+	}
+
+	var matches Tweets
+	for _, tweet := range tweets {
+		if filter(tweet) {
+			matches = append(matches, tweet)
+		}
+	}
+
+	start, end := paginate(len(matches), apiPage(r))
+
+	var sb strings.Builder
+	for _, tweet := range matches[start:end] {
+		fmt.Fprintf(
+			&sb, "%s\t%s\t%s\t%s\n",
+			tweet.Tweeter.Nick, tweet.Tweeter.URL,
+			tweet.Created.UTC().Format(time.RFC3339), tweet.Text,
+		)
+	}
+
+	writePlain(w, http.StatusOK, sb.String())
+}
+
+// APIUsersHandler lists the feeds in the registry, or registers a new one
+func (s *Server) APIUsersHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		if r.Method == http.MethodPost {
+			ip := RemoteIP(r)
+			if s.registryLimiter.Wait(ip) > 0 {
+				writePlain(w, http.StatusTooManyRequests, "Too Many Requests\n")
+				return
+			}
+			s.registryLimiter.Hit(ip)
+
+			nick, url := r.FormValue("nickname"), r.FormValue("url")
+			if err := ValidateFeed(nick, url); err != nil {
+				writePlain(w, http.StatusBadRequest, "Bad Request\n")
+				return
+			}
+
+			if err := VerifyFeed(nick, url); err == ErrFeedNotVerified {
+				writePlain(w, http.StatusBadRequest, fmt.Sprintf("Bad Request: %s\n", err))
+				return
+			} else if err != nil {
+				log.WithError(err).Warnf("error verifying feed %s %s", nick, url)
+				writePlain(w, http.StatusBadRequest, "Bad Request: error fetching feed\n")
+				return
+			}
+
+			feed, err := RegisterFeed(s.db, nick, url)
+			if err == ErrRegistryFull {
+				writePlain(w, http.StatusServiceUnavailable, "Service Unavailable\n")
+				return
+			} else if err != nil {
+				log.WithError(err).Error("error registering feed")
+				writePlain(w, http.StatusInternalServerError, "Internal Server Error\n")
+				return
+			}
+
+			log.Infof("feed registered: %s %s", feed.Nick, feed.URL)
+			writePlain(w, http.StatusOK, "OK\n")
+			return
+		}
+
+		feeds, err := SearchRegistry(s.db, r.FormValue("q"))
+		if err != nil {
+			log.WithError(err).Error("error searching registry")
+			writePlain(w, http.StatusInternalServerError, "Internal Server Error\n")
+			return
+		}
+
+		sort.SliceStable(feeds, func(i, j int) bool {
+			return feeds[i].CreatedAt.After(feeds[j].CreatedAt)
+		})
+
+		start, end := paginate(len(feeds), apiPage(r))
+
+		var sb strings.Builder
+		for _, feed := range feeds[start:end] {
+			fmt.Fprintf(&sb, "%s\t%s\t%s\n", feed.CreatedAt.UTC().Format(time.RFC3339), feed.Nick, feed.URL)
+		}
+
+		writePlain(w, http.StatusOK, sb.String())
+	}
+}
+
+// APITweetsHandler lists the latest tweets, optionally containing a query
+func (s *Server) APITweetsHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		query := strings.ToLower(r.FormValue("q"))
+
+		s.serveRegistryTweets(w, r, func(tweet Tweet) bool {
+			return strings.Contains(strings.ToLower(tweet.Text), query)
+		})
+	}
+}
+
+// APIMentionsHandler lists the latest tweets mentioning a feed
+func (s *Server) APIMentionsHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		url := NormalizeURL(r.FormValue("url"))
+		if url == "" {
+			writePlain(w, http.StatusBadRequest, "Bad Request\n")
+			return
+		}
+
+		s.serveRegistryTweets(w, r, func(tweet Tweet) bool {
+			return mentionsURL(tweet.Text, url)
+		})
+	}
+}
+
+// APITagsHandler lists the latest tweets tagged with a tag
+func (s *Server) APITagsHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		tag := p.ByName("tag")
+
+		s.serveRegistryTweets(w, r, func(tweet Tweet) bool {
+			return tweet.HasTag(tag)
+		})
+	}
+}
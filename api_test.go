@@ -0,0 +1,47 @@
+package twtxt
+
+import (
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	testCases := []struct {
+		n, page    int
+		start, end int
+	}{
+		{0, 1, 0, 0},
+		{5, 1, 0, 5},
+		{45, 2, 20, 40},
+		{45, 3, 40, 45},
+		{45, 4, 45, 45},
+	}
+
+	for _, testCase := range testCases {
+		start, end := paginate(testCase.n, testCase.page)
+		if start != testCase.start || end != testCase.end {
+			t.Errorf("paginate(%d, %d): expected [%d:%d] got [%d:%d]",
+				testCase.n, testCase.page, testCase.start, testCase.end, start, end)
+		}
+	}
+}
+
+func TestMentionsURL(t *testing.T) {
+	url := NormalizeURL("https://example.com/u/alice")
+
+	testCases := []struct {
+		text     string
+		expected bool
+	}{
+		{"hi @<alice https://example.com/u/alice>", true},
+		{"hi @<https://example.com/u/alice>", true},
+		{"hi @<alice http://example.com/u/alice>", true},
+		{"hi @<alice https://example.com/u/alice2>", false},
+		{"see https://example.com/u/alice", false},
+	}
+
+	for _, testCase := range testCases {
+		if actual := mentionsURL(testCase.text, url); actual != testCase.expected {
+			t.Errorf("mentionsURL(%q): expected %v got %v", testCase.text, testCase.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package twtxt
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	auditFile = "audit.log"
+)
+
+// SecurityEvent logs a security relevant event such as a failed login or a
+// lockout along with the client that caused it
+func SecurityEvent(r *http.Request, event, username string) {
+	log.WithFields(log.Fields{
+		"event":      event,
+		"username":   username,
+		"remote":     RemoteIP(r),
+		"path":       r.URL.Path,
+		"user_agent": r.UserAgent(),
+	}).Warn("security event")
+}
+
+// AuditLog records an administrative action performed by actor against
+// target both in the server log and in an append-only audit log kept in the
+// data directory.
+func AuditLog(path, actor, action, target string) {
+	log.WithFields(log.Fields{
+		"actor":  actor,
+		"action": action,
+		"target": target,
+	}).Info("audit")
+
+	if err := os.MkdirAll(path, 0755); err != nil {
+		log.WithError(err).Error("error creating data directory")
+		return
+	}
+
+	f, err := os.OpenFile(
+		filepath.Join(path, auditFile),
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600,
+	)
+	if err != nil {
+		log.WithError(err).Error("error opening audit log")
+		return
+	}
+	defer f.Close()
+
+	line := fmt.Sprintf(
+		"%s\t%s\t%s\t%s\n",
+		time.Now().Format(time.RFC3339), actor, action, target,
+	)
+	if _, err := f.WriteString(line); err != nil {
+		log.WithError(err).Error("error writing audit log")
+	}
+}
@@ -1,7 +1,9 @@
 package auth
 
 import (
+	"net"
 	"net/http"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/prologic/twtxt/session"
@@ -11,11 +13,46 @@ import (
 type Options struct {
 	login    string
 	register string
+
+	header  string
+	trusted []*net.IPNet
 }
 
 // NewOptions ...
 func NewOptions(login, register string) *Options {
-	return &Options{login, register}
+	return &Options{login: login, register: register}
+}
+
+// WithProxyAuth trusts the authenticated username given in `header` by
+// reverse proxies whose address is in one of the `trusted` networks
+func (o *Options) WithProxyAuth(header string, trusted []*net.IPNet) *Options {
+	o.header = header
+	o.trusted = trusted
+	return o
+}
+
+// ParseCIDRs parses a list of networks in CIDR notation, or single
+// addresses, as used for trusted proxies
+func ParseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
 }
 
 // Manager ...
@@ -28,6 +65,33 @@ func NewManager(options *Options) *Manager {
 	return &Manager{options}
 }
 
+// RemoteUser returns the username a trusted reverse proxy authenticated the
+// request as, or an empty string if proxy authentication is disabled, the
+// request did not come from a trusted proxy or it has no username.
+func (m *Manager) RemoteUser(r *http.Request) string {
+	if m.options.header == "" {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ""
+	}
+
+	for _, network := range m.options.trusted {
+		if network.Contains(ip) {
+			return strings.TrimSpace(r.Header.Get(m.options.header))
+		}
+	}
+
+	return ""
+}
+
 // MustAuth ...
 func (m *Manager) MustAuth(next httprouter.Handle) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRemoteUser(t *testing.T) {
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8", "192.0.2.1", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManager(NewOptions("/login", "/register").WithProxyAuth("X-Remote-User", trusted))
+
+	testCases := []struct {
+		name       string
+		remoteAddr string
+		header     string
+		expected   string
+	}{
+		{"trusted network", "10.1.2.3:1234", "alice", "alice"},
+		{"trusted address", "192.0.2.1:1234", "alice", "alice"},
+		{"trusted ipv6 address", "[::1]:1234", "alice", "alice"},
+		{"untrusted address", "192.0.2.2:1234", "alice", ""},
+		{"missing header", "10.1.2.3:1234", "", ""},
+		{"whitespace", "10.1.2.3:1234", " alice ", "alice"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tc.remoteAddr
+			if tc.header != "" {
+				r.Header.Set("X-Remote-User", tc.header)
+			}
+
+			if actual := m.RemoteUser(r); actual != tc.expected {
+				t.Errorf("expected %q got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRemoteUserDisabled(t *testing.T) {
+	m := NewManager(NewOptions("/login", "/register"))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "127.0.0.1:1234"
+	r.Header.Set("X-Remote-User", "alice")
+
+	if actual := m.RemoteUser(r); actual != "" {
+		t.Errorf("expected proxy authentication to be disabled got %q", actual)
+	}
+}
+
+func TestParseCIDRs(t *testing.T) {
+	if _, err := ParseCIDRs([]string{"not-an-ip"}); err == nil {
+		t.Error("expected an error parsing an invalid network")
+	}
+}
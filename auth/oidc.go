@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	oidc "github.com/coreos/go-oidc"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// DefaultUsernameClaim is the ID token claim users are named after
+	DefaultUsernameClaim = "preferred_username"
+
+	// DefaultEmailClaim is the ID token claim holding a user's email address
+	DefaultEmailClaim = "email"
+)
+
+var (
+	// ErrNoIDToken is returned when the token response has no ID token
+	ErrNoIDToken = errors.New("error: no id_token in token response")
+
+	// ErrInvalidNonce is returned when the ID token's nonce does not match
+	// the one the authentication request was made with
+	ErrInvalidNonce = errors.New("error: invalid nonce in id_token")
+)
+
+// OIDCOptions ...
+type OIDCOptions struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+
+	usernameClaim string
+	emailClaim    string
+}
+
+// NewOIDCOptions ...
+func NewOIDCOptions(issuer, clientID, clientSecret, redirectURL, usernameClaim, emailClaim string) *OIDCOptions {
+	if usernameClaim == "" {
+		usernameClaim = DefaultUsernameClaim
+	}
+	if emailClaim == "" {
+		emailClaim = DefaultEmailClaim
+	}
+
+	return &OIDCOptions{
+		issuer, clientID, clientSecret, redirectURL,
+		usernameClaim, emailClaim,
+	}
+}
+
+// Identity is a user's verified identity at an OpenID Connect provider
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Username      string
+	Email         string
+	EmailVerified bool
+
+	// AuthTime is when the user last authenticated with the provider, zero
+	// if the provider did not say
+	AuthTime time.Time
+}
+
+// OIDCProvider authenticates users against an OpenID Connect provider using
+// the authorization code flow
+type OIDCProvider struct {
+	options  *OIDCOptions
+	verifier *oidc.IDTokenVerifier
+	config   oauth2.Config
+}
+
+// NewOIDCProvider discovers the provider's endpoints and keys from its issuer
+func NewOIDCProvider(ctx context.Context, options *OIDCOptions) (*OIDCProvider, error) {
+	provider, err := oidc.NewProvider(ctx, options.issuer)
+	if err != nil {
+		return nil, fmt.Errorf("error discovering oidc provider %s: %w", options.issuer, err)
+	}
+
+	return &OIDCProvider{
+		options:  options,
+		verifier: provider.Verifier(&oidc.Config{ClientID: options.clientID}),
+		config: oauth2.Config{
+			ClientID:     options.clientID,
+			ClientSecret: options.clientSecret,
+			RedirectURL:  options.redirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+		},
+	}, nil
+}
+
+// AuthCodeURL returns the provider's URL to redirect the user to for
+// authentication. The state and nonce must be kept by the caller and given
+// back on the callback to prevent forged and replayed responses.
+func (p *OIDCProvider) AuthCodeURL(state, nonce string) string {
+	return p.config.AuthCodeURL(state, oidc.Nonce(nonce))
+}
+
+// ReauthCodeURL is AuthCodeURL asking the provider to authenticate the user
+// again even if they are logged in there, to confirm sensitive actions
+func (p *OIDCProvider) ReauthCodeURL(state, nonce string) string {
+	return p.config.AuthCodeURL(
+		state, oidc.Nonce(nonce),
+		oauth2.SetAuthURLParam("prompt", "login"),
+		oauth2.SetAuthURLParam("max_age", "0"),
+	)
+}
+
+// Exchange redeems the authorization code from the provider's callback,
+// verifies the ID token it is given and returns the identity it asserts
+func (p *OIDCProvider) Exchange(ctx context.Context, code, nonce string) (*Identity, error) {
+	token, err := p.config.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("error exchanging authorization code: %w", err)
+	}
+
+	raw, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, ErrNoIDToken
+	}
+
+	idToken, err := p.verifier.Verify(ctx, raw)
+	if err != nil {
+		return nil, fmt.Errorf("error verifying id_token: %w", err)
+	}
+
+	if idToken.Nonce != nonce {
+		return nil, ErrInvalidNonce
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("error decoding id_token claims: %w", err)
+	}
+
+	identity := &Identity{
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+	}
+	identity.Username, _ = claims[p.options.usernameClaim].(string)
+	identity.Email, _ = claims[p.options.emailClaim].(string)
+	identity.EmailVerified, _ = claims["email_verified"].(bool)
+	if authTime, ok := claims["auth_time"].(float64); ok {
+		identity.AuthTime = time.Unix(int64(authTime), 0)
+	}
+
+	return identity, nil
+}
@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// testProvider is a minimal stand-in OpenID Connect provider that issues an
+// ID token with the configured claims for any authorization code
+type testProvider struct {
+	*httptest.Server
+
+	key    *rsa.PrivateKey
+	claims map[string]interface{}
+	nonce  string
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &testProvider{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                p.URL,
+			"authorization_endpoint":                p.URL + "/authorize",
+			"token_endpoint":                        p.URL + "/token",
+			"jwks_uri":                              p.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{
+			Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "test-code" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "test-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     p.idToken(t),
+		})
+	})
+
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+func (p *testProvider) idToken(t *testing.T) string {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: p.key},
+		(&jose.SignerOptions{}).WithHeader("kid", "test"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":   p.URL,
+		"sub":   "1234",
+		"aud":   "twtxt",
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"nonce": p.nonce,
+	}
+	for k, v := range p.claims {
+		claims[k] = v
+	}
+
+	raw, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestOIDCProvider(t *testing.T) {
+	idp := newTestProvider(t)
+	defer idp.Close()
+
+	idp.nonce = "test-nonce"
+	idp.claims = map[string]interface{}{
+		"preferred_username": "alice",
+		"mail":               "alice@example.com",
+		"email_verified":     true,
+		"auth_time":          1600000000,
+	}
+
+	ctx := context.Background()
+	p, err := NewOIDCProvider(ctx, NewOIDCOptions(
+		idp.URL, "twtxt", "secret", "http://localhost/login/oidc/callback",
+		"", "mail",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(p.AuthCodeURL("test-state", "test-nonce"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != "/authorize" || u.Query().Get("state") != "test-state" || u.Query().Get("nonce") != "test-nonce" {
+		t.Errorf("unexpected auth code url %s", u)
+	}
+
+	u, err = url.Parse(p.ReauthCodeURL("test-state", "test-nonce"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Query().Get("prompt") != "login" || u.Query().Get("max_age") != "0" {
+		t.Errorf("unexpected reauth code url %s", u)
+	}
+
+	identity, err := p.Exchange(ctx, "test-code", "test-nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Identity{
+		Issuer:        idp.URL,
+		Subject:       "1234",
+		Username:      "alice",
+		Email:         "alice@example.com",
+		EmailVerified: true,
+		AuthTime:      time.Unix(1600000000, 0),
+	}
+	if *identity != expected {
+		t.Errorf("expected identity %+v got %+v", expected, *identity)
+	}
+
+	if _, err := p.Exchange(ctx, "test-code", "other-nonce"); err != ErrInvalidNonce {
+		t.Errorf("expected ErrInvalidNonce got %v", err)
+	}
+
+	if _, err := p.Exchange(ctx, "bogus-code", "test-nonce"); err == nil {
+		t.Error("expected an error exchanging an invalid code")
+	}
+}
+
+func TestOIDCProviderWrongAudience(t *testing.T) {
+	idp := newTestProvider(t)
+	defer idp.Close()
+
+	ctx := context.Background()
+	p, err := NewOIDCProvider(ctx, NewOIDCOptions(
+		idp.URL, "other-client", "secret", "http://localhost/login/oidc/callback",
+		"", "",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.Exchange(ctx, "test-code", ""); err == nil {
+		t.Error("expected an error for an id_token issued to another client")
+	}
+}
@@ -2,6 +2,7 @@ package twtxt
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/prologic/bitcask"
 )
@@ -39,6 +40,14 @@ func (bs *BitcaskStore) SetUser(username string, user *User) error {
 	return nil
 }
 
+func (bs *BitcaskStore) DelUser(username string) error {
+	key := []byte(fmt.Sprintf("/users/%s", username))
+	if !bs.db.Has(key) {
+		return ErrUserNotFound
+	}
+	return bs.db.Delete(key)
+}
+
 func (bs *BitcaskStore) GetAllUsers() ([]*User, error) {
 	var users []*User
 
@@ -81,3 +90,285 @@ func (bs *BitcaskStore) SetSession(sid string, session *Session) error {
 	}
 	return nil
 }
+
+func (bs *BitcaskStore) GetToken(key string) (*Token, error) {
+	data, err := bs.db.Get([]byte(fmt.Sprintf("/tokens/%s", key)))
+	if err == bitcask.ErrKeyNotFound {
+		return nil, ErrTokenNotFound
+	}
+	return LoadToken(data)
+}
+
+func (bs *BitcaskStore) SetToken(key string, token *Token) error {
+	data, err := token.Bytes()
+	if err != nil {
+		return err
+	}
+
+	if err := bs.db.Put([]byte(fmt.Sprintf("/tokens/%s", key)), data); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (bs *BitcaskStore) DelToken(key string) error {
+	return bs.db.Delete([]byte(fmt.Sprintf("/tokens/%s", key)))
+}
+
+func (bs *BitcaskStore) GetInvite(code string) (*Invite, error) {
+	data, err := bs.db.Get([]byte(fmt.Sprintf("/invites/%s", code)))
+	if err == bitcask.ErrKeyNotFound {
+		return nil, ErrInviteNotFound
+	}
+	return LoadInvite(data)
+}
+
+func (bs *BitcaskStore) SetInvite(code string, invite *Invite) error {
+	data, err := invite.Bytes()
+	if err != nil {
+		return err
+	}
+
+	if err := bs.db.Put([]byte(fmt.Sprintf("/invites/%s", code)), data); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (bs *BitcaskStore) DelInvite(code string) error {
+	return bs.db.Delete([]byte(fmt.Sprintf("/invites/%s", code)))
+}
+
+func (bs *BitcaskStore) GetAllInvites() ([]*Invite, error) {
+	var invites []*Invite
+
+	err := bs.db.Scan([]byte("/invites"), func(key []byte) error {
+		data, err := bs.db.Get(key)
+		if err != nil {
+			return err
+		}
+
+		invite, err := LoadInvite(data)
+		if err != nil {
+			return err
+		}
+		invites = append(invites, invite)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return invites, nil
+}
+
+func (bs *BitcaskStore) GetIdentity(key string) (*Identity, error) {
+	data, err := bs.db.Get([]byte(fmt.Sprintf("/identities/%s", key)))
+	if err == bitcask.ErrKeyNotFound {
+		return nil, ErrIdentityNotFound
+	}
+	return LoadIdentity(data)
+}
+
+func (bs *BitcaskStore) SetIdentity(key string, identity *Identity) error {
+	data, err := identity.Bytes()
+	if err != nil {
+		return err
+	}
+
+	if err := bs.db.Put([]byte(fmt.Sprintf("/identities/%s", key)), data); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (bs *BitcaskStore) DelIdentity(key string) error {
+	return bs.db.Delete([]byte(fmt.Sprintf("/identities/%s", key)))
+}
+
+func (bs *BitcaskStore) GetAllIdentities() ([]*Identity, error) {
+	var identities []*Identity
+
+	err := bs.db.Scan([]byte("/identities"), func(key []byte) error {
+		data, err := bs.db.Get(key)
+		if err != nil {
+			return err
+		}
+
+		identity, err := LoadIdentity(data)
+		if err != nil {
+			return err
+		}
+		identities = append(identities, identity)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return identities, nil
+}
+
+func (bs *BitcaskStore) GetDraft(id string) (*Draft, error) {
+	data, err := bs.db.Get([]byte(fmt.Sprintf("/drafts/%s", id)))
+	if err == bitcask.ErrKeyNotFound {
+		return nil, ErrDraftNotFound
+	}
+	return LoadDraft(data)
+}
+
+func (bs *BitcaskStore) SetDraft(id string, draft *Draft) error {
+	data, err := draft.Bytes()
+	if err != nil {
+		return err
+	}
+
+	if err := bs.db.Put([]byte(fmt.Sprintf("/drafts/%s", id)), data); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (bs *BitcaskStore) DelDraft(id string) error {
+	return bs.db.Delete([]byte(fmt.Sprintf("/drafts/%s", id)))
+}
+
+func (bs *BitcaskStore) GetAllDrafts() ([]*Draft, error) {
+	var drafts []*Draft
+
+	err := bs.db.Scan([]byte("/drafts"), func(key []byte) error {
+		data, err := bs.db.Get(key)
+		if err != nil {
+			return err
+		}
+
+		draft, err := LoadDraft(data)
+		if err != nil {
+			return err
+		}
+		drafts = append(drafts, draft)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return drafts, nil
+}
+
+func (bs *BitcaskStore) GetMention(username, id string) (*Mention, error) {
+	data, err := bs.db.Get([]byte(fmt.Sprintf("/mentions/%s/%s", username, id)))
+	if err == bitcask.ErrKeyNotFound {
+		return nil, ErrMentionNotFound
+	}
+	return LoadMention(data)
+}
+
+func (bs *BitcaskStore) SetMention(username, id string, mention *Mention) error {
+	data, err := mention.Bytes()
+	if err != nil {
+		return err
+	}
+
+	if err := bs.db.Put([]byte(fmt.Sprintf("/mentions/%s/%s", username, id)), data); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (bs *BitcaskStore) DelMention(username, id string) error {
+	return bs.db.Delete([]byte(fmt.Sprintf("/mentions/%s/%s", username, id)))
+}
+
+func (bs *BitcaskStore) GetMentions(username string) ([]*Mention, error) {
+	var mentions []*Mention
+
+	err := bs.db.Scan([]byte(fmt.Sprintf("/mentions/%s/", username)), func(key []byte) error {
+		data, err := bs.db.Get(key)
+		if err != nil {
+			return err
+		}
+
+		mention, err := LoadMention(data)
+		if err != nil {
+			return err
+		}
+		mentions = append(mentions, mention)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mentions, nil
+}
+
+func (bs *BitcaskStore) GetUnreadMentions(username string) (int, error) {
+	data, err := bs.db.Get([]byte(fmt.Sprintf("/unread/%s", username)))
+	if err == bitcask.ErrKeyNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(data))
+}
+
+// SetUnreadMentions stores the number of unread mentions of the user,
+// dropping the counter altogether when there are none
+func (bs *BitcaskStore) SetUnreadMentions(username string, unread int) error {
+	key := []byte(fmt.Sprintf("/unread/%s", username))
+	if unread <= 0 {
+		if err := bs.db.Delete(key); err != nil && err != bitcask.ErrKeyNotFound {
+			return err
+		}
+		return nil
+	}
+	return bs.db.Put(key, []byte(strconv.Itoa(unread)))
+}
+
+func (bs *BitcaskStore) GetKnownFeed(key string) (*KnownFeed, error) {
+	data, err := bs.db.Get([]byte(fmt.Sprintf("/registry/%s", key)))
+	if err == bitcask.ErrKeyNotFound {
+		return nil, ErrFeedNotFound
+	}
+	return LoadKnownFeed(data)
+}
+
+func (bs *BitcaskStore) SetKnownFeed(key string, feed *KnownFeed) error {
+	data, err := feed.Bytes()
+	if err != nil {
+		return err
+	}
+
+	if err := bs.db.Put([]byte(fmt.Sprintf("/registry/%s", key)), data); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (bs *BitcaskStore) DelKnownFeed(key string) error {
+	return bs.db.Delete([]byte(fmt.Sprintf("/registry/%s", key)))
+}
+
+func (bs *BitcaskStore) GetAllKnownFeeds() ([]*KnownFeed, error) {
+	var feeds []*KnownFeed
+
+	err := bs.db.Scan([]byte("/registry/"), func(key []byte) error {
+		data, err := bs.db.Get(key)
+		if err != nil {
+			return err
+		}
+
+		feed, err := LoadKnownFeed(data)
+		if err != nil {
+			return err
+		}
+		feeds = append(feeds, feed)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return feeds, nil
+}
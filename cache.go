@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"encoding/gob"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -17,6 +18,9 @@ import (
 type Cached struct {
 	Tweets       Tweets
 	Lastmodified string
+
+	// Metadata holds the metadata comments of the feed, see ParseFeedMetadata
+	Metadata map[string]string
 }
 
 // key: url
@@ -79,8 +83,141 @@ func LoadCache(path string) (Cache, error) {
 	return cache, nil
 }
 
+// sharedCache is the cache last loaded by SharedCache along with the
+// modification time and size of its file when it was loaded
+var sharedCache struct {
+	sync.Mutex
+
+	path     string
+	modified time.Time
+	size     int64
+	version  uint64
+	cache    Cache
+}
+
+// SharedCache is like LoadCache but only decodes the cache again when its
+// file has changed since the last call. The cache returned is shared by all
+// callers and must not be modified.
+func SharedCache(path string) (Cache, error) {
+	cache, _, err := loadSharedCache(path)
+	return cache, err
+}
+
+// loadSharedCache is SharedCache also returning a version of the cache that
+// changes whenever it is decoded again
+func loadSharedCache(path string) (Cache, uint64, error) {
+	sharedCache.Lock()
+	defer sharedCache.Unlock()
+
+	stat, err := os.Stat(filepath.Join(path, "cache"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, 0, err
+	}
+
+	var (
+		modified time.Time
+		size     int64
+	)
+	if err == nil {
+		modified, size = stat.ModTime(), stat.Size()
+	}
+
+	if sharedCache.cache != nil && sharedCache.path == path &&
+		sharedCache.modified.Equal(modified) && sharedCache.size == size {
+		return sharedCache.cache, sharedCache.version, nil
+	}
+
+	cache, err := LoadCache(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sharedCache.path = path
+	sharedCache.modified = modified
+	sharedCache.size = size
+	sharedCache.version++
+	sharedCache.cache = cache
+
+	return cache, sharedCache.version, nil
+}
+
 const maxfetchers = 50
 
+// feedClient fetches the feeds users follow or registered, which may be at
+// any URL, so it never connects to internal services
+var feedClient = newFetchClient(15 * time.Second)
+
+// MaxFeedSize is the most kept of a feed when fetching it, only the latest
+// tweets of larger feeds are kept
+const MaxFeedSize = 4 << 20
+
+// maxFeedHeaderSize is the most kept of the comments at the top of a feed
+// cut to MaxFeedSize, which hold its metadata
+const maxFeedHeaderSize = 64 << 10
+
+// feedHeader returns the comment lines at the top of the feed
+func feedHeader(data []byte) []byte {
+	end := 0
+	for end < len(data) && data[end] == '#' {
+		i := bytes.IndexByte(data[end:], '\n')
+		if i < 0 || end+i+1 > maxFeedHeaderSize {
+			break
+		}
+		end += i + 1
+	}
+	return append([]byte(nil), data[:end]...)
+}
+
+// readFeed reads a feed keeping at most its last MaxFeedSize, where its
+// latest tweets are, from the start of a line. The comments at the top of a
+// feed that is cut are kept too. It reports whether the feed was cut.
+func readFeed(r io.Reader) ([]byte, bool, error) {
+	var (
+		head, data []byte
+		truncated  bool
+	)
+
+	cut := func() {
+		if !truncated {
+			head = feedHeader(data)
+			truncated = true
+		}
+		data = append(data[:0], data[len(data)-MaxFeedSize:]...)
+	}
+
+	chunk := make([]byte, 32<<10)
+	for {
+		n, err := r.Read(chunk)
+		data = append(data, chunk[:n]...)
+		if len(data) > 2*MaxFeedSize {
+			cut()
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, false, err
+		}
+	}
+
+	if len(data) > MaxFeedSize {
+		cut()
+	}
+	if !truncated {
+		return data, false, nil
+	}
+
+	// Drop the tweet cut in half
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		data = data[i+1:]
+	} else {
+		data = nil
+	}
+
+	return append(head, data...), true, nil
+}
+
+// FetchTweets fetches the feeds of sources, the nicks of feeds by their URL,
+// into the cache
 func (cache Cache) FetchTweets(sources map[string]string) {
 	var mu sync.RWMutex
 
@@ -92,12 +229,16 @@ func (cache Cache) FetchTweets(sources map[string]string) {
 	// max parallel http fetchers
 	var fetchers = make(chan struct{}, maxfetchers)
 
-	for nick, url := range sources {
+	for url, nick := range sources {
 		wg.Add(1)
 		fetchers <- struct{}{}
 		// anon func takes needed variables as arg, avoiding capture of iterator variables
 		go func(nick string, url string) {
 			defer func() {
+				// A bad feed must not take the whole server down
+				if err := recover(); err != nil {
+					log.Errorf("%s: panic fetching feed: %v", url, err)
+				}
 				<-fetchers
 				wg.Done()
 			}()
@@ -119,10 +260,7 @@ func (cache Cache) FetchTweets(sources map[string]string) {
 			}
 			mu.RUnlock()
 
-			client := http.Client{
-				Timeout: time.Second * 15,
-			}
-			resp, err := client.Do(req)
+			resp, err := feedClient.Do(req)
 			if err != nil {
 				log.WithError(err).Errorf("%s: client.Do fail: %s", url, err)
 				tweetsch <- nil
@@ -140,11 +278,27 @@ func (cache Cache) FetchTweets(sources map[string]string) {
 
 			switch resp.StatusCode {
 			case http.StatusOK: // 200
-				scanner := bufio.NewScanner(resp.Body)
-				tweets = ParseFile(scanner, Tweeter{Nick: nick, URL: url})
+				data, truncated, err := readFeed(resp.Body)
+				if err != nil {
+					log.WithError(err).Errorf("%s: error reading feed: %s", url, err)
+					break
+				}
+				if truncated {
+					log.Warnf("%s: feed larger than %d bytes truncated", url, MaxFeedSize)
+				}
+				scanner := bufio.NewScanner(bytes.NewReader(data))
+				tweets, err = ParseFile(scanner, Tweeter{Nick: nick, URL: url})
+				if err != nil {
+					log.WithError(err).Errorf("%s: error parsing feed: %s", url, err)
+					break
+				}
 				lastmodified := resp.Header.Get("Last-Modified")
 				mu.Lock()
-				cache[url] = Cached{Tweets: tweets, Lastmodified: lastmodified}
+				cache[url] = Cached{
+					Tweets:       tweets,
+					Lastmodified: lastmodified,
+					Metadata:     ParseFeedMetadata(data),
+				}
 				mu.Unlock()
 			case http.StatusNotModified: // 304
 				mu.RLock()
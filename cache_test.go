@@ -0,0 +1,42 @@
+package twtxt
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReadFeed(t *testing.T) {
+	small := "# nick = alice\n2020-07-20T12:00:00Z\thello\n"
+	data, truncated, err := readFeed(strings.NewReader(small))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if truncated || string(data) != small {
+		t.Errorf("expected %q untouched got %q (truncated: %t)", small, data, truncated)
+	}
+
+	var feed bytes.Buffer
+	feed.WriteString("# nick = alice\n# description = big\n")
+	for i := 0; feed.Len() < 3*MaxFeedSize; i++ {
+		feed.WriteString("2020-07-20T12:00:00Z\tan old tweet that is repeated many times\n")
+	}
+	feed.WriteString("2020-07-21T12:00:00Z\tthe latest tweet\n")
+
+	data, truncated, err = readFeed(&feed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !truncated {
+		t.Error("expected feed to be truncated")
+	}
+	if len(data) > MaxFeedSize+maxFeedHeaderSize {
+		t.Errorf("expected at most %d bytes got %d", MaxFeedSize+maxFeedHeaderSize, len(data))
+	}
+	if !bytes.HasPrefix(data, []byte("# nick = alice\n# description = big\n2020-")) {
+		t.Errorf("expected metadata followed by whole tweets got %q", data[:100])
+	}
+	if !bytes.HasSuffix(data, []byte("\tthe latest tweet\n")) {
+		t.Errorf("expected latest tweet to be kept got %q", data[len(data)-100:])
+	}
+}
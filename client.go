@@ -0,0 +1,122 @@
+package twtxt
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned when fetching something from a loopback,
+// private or link-local address, which could be used to reach services that
+// are not meant to be public through us
+var ErrPrivateAddress = errors.New("error: refusing to connect to a private address")
+
+// privateNetworks are the networks not covered by the net.IP predicates that
+// must not be reached when fetching remote resources
+var privateNetworks = func() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8",
+		"10.0.0.0/8",
+		"100.64.0.0/10",
+		"172.16.0.0/12",
+		"192.168.0.0/16",
+		"198.18.0.0/15",
+		"fc00::/7",
+	} {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}()
+
+// isPublicIP reports whether the IP is a public unicast address
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+		return false
+	}
+
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// allowedAddress reports whether remote resources may be fetched from the
+// IP, tests replace it to fetch from local servers
+var allowedAddress = isPublicIP
+
+// localHosts are the host:port of this instance, whose own feeds may be
+// fetched whatever address it is at
+var localHosts sync.Map
+
+// trustLocalHost lets the instance at baseURL be fetched from even when it
+// is at a private address
+func trustLocalHost(baseURL string) {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Hostname() == "" {
+		return
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	localHosts.Store(net.JoinHostPort(u.Hostname(), port), true)
+}
+
+// dialPublic connects to addr like net.Dialer but refuses addresses that
+// are not public once the host is resolved, so that neither URLs nor DNS
+// records nor redirects can point us at internal services
+func dialPublic(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+
+	if _, ok := localHosts.Load(addr); !ok {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !allowedAddress(ip) {
+				return ErrPrivateAddress
+			}
+			return nil
+		}
+	}
+
+	return dialer.DialContext(ctx, network, addr)
+}
+
+// newFetchClient returns a http client for fetching remote resources, such
+// as feeds, that only connects to public addresses
+func newFetchClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:           dialPublic,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}
+}
@@ -0,0 +1,68 @@
+package twtxt
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// allowPrivateAddresses lets tests fetch from local servers until the
+// returned func is called
+func allowPrivateAddresses() func() {
+	allowedAddress = func(net.IP) bool { return true }
+	return func() { allowedAddress = isPublicIP }
+}
+
+func TestIsPublicIP(t *testing.T) {
+	testCases := []struct {
+		ip       string
+		expected bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"0.0.0.0", false},
+		{"10.1.2.3", false},
+		{"172.20.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+	}
+
+	for _, tc := range testCases {
+		if actual := isPublicIP(net.ParseIP(tc.ip)); actual != tc.expected {
+			t.Errorf("isPublicIP(%s): expected %v got %v", tc.ip, tc.expected, actual)
+		}
+	}
+}
+
+func TestFetchClientRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "secret")
+	}))
+	defer server.Close()
+
+	client := newFetchClient(5 * time.Second)
+
+	if _, err := client.Get(server.URL); err == nil || !strings.Contains(err.Error(), ErrPrivateAddress.Error()) {
+		t.Errorf("expected ErrPrivateAddress got %v", err)
+	}
+
+	// Our own instance may be fetched from wherever it is
+	trustLocalHost(server.URL)
+	defer localHosts.Delete(strings.TrimPrefix(server.URL, "http://"))
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
@@ -4,6 +4,16 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	secretEnv  = "TWTXT_SECRET"
+	secretFile = "secret"
 )
 
 // Config contains the server configuration parameters
@@ -14,6 +24,82 @@ type Config struct {
 	BaseURL         string `json:"base_url"`
 	Register        bool   `json:"register"`
 	RegisterMessage string `json:"register_message"`
+	InviteOnly      bool   `json:"invite_only"`
+	AdminUser       string `json:"admin_user"`
+	Secret          string `json:"secret"`
+	Mailer          string `json:"mailer"`
+	MailFrom        string `json:"mail_from"`
+	PasswordHasher  string `json:"password_hasher"`
+
+	SessionExpiry         time.Duration `json:"session_expiry"`
+	SessionRememberExpiry time.Duration `json:"session_remember_expiry"`
+
+	OIDCIssuer        string `json:"oidc_issuer"`
+	OIDCClientID      string `json:"oidc_client_id"`
+	OIDCClientSecret  string `json:"oidc_client_secret"`
+	OIDCName          string `json:"oidc_name"`
+	OIDCUsernameClaim string `json:"oidc_username_claim"`
+	OIDCEmailClaim    string `json:"oidc_email_claim"`
+	OIDCAutoProvision bool   `json:"oidc_auto_provision"`
+
+	AuthHeader     string   `json:"auth_header"`
+	TrustedProxies []string `json:"trusted_proxies"`
+}
+
+// IsSecure returns whether the instance is served over HTTPS and cookies
+// should only be sent over secure connections
+func (c *Config) IsSecure() bool {
+	return strings.HasPrefix(strings.ToLower(c.BaseURL), "https://")
+}
+
+// OIDCEnabled returns whether users can log in with an OpenID Connect provider
+func (c *Config) OIDCEnabled() bool {
+	return c.OIDCIssuer != ""
+}
+
+// LoadSecret ensures the config has a secret used to sign sessions and
+// tokens. If one is not configured it is read from the TWTXT_SECRET
+// environment variable or the secret file in the data directory, and failing
+// that a new secret is generated and persisted there for future runs.
+func (c *Config) LoadSecret() error {
+	if c.Secret != "" {
+		return nil
+	}
+
+	if secret := os.Getenv(secretEnv); secret != "" {
+		c.Secret = secret
+		return nil
+	}
+
+	fn := filepath.Join(c.Data, secretFile)
+
+	data, err := ioutil.ReadFile(fn)
+	if err == nil {
+		if secret := strings.TrimSpace(string(data)); secret != "" {
+			c.Secret = secret
+			return nil
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	secret, err := GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.Data, 0755); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(fn, []byte(secret+"\n"), 0600); err != nil {
+		return err
+	}
+
+	log.Infof("generated new secret in %s", fn)
+	c.Secret = secret
+
+	return nil
 }
 
 // Load loads a configuration from the given path
@@ -1,8 +1,9 @@
 package twtxt
 
 import (
-	"fmt"
+	"html/template"
 	"net/http"
+	"strings"
 
 	"github.com/prologic/twtxt/session"
 	log "github.com/sirupsen/logrus"
@@ -10,26 +11,68 @@ import (
 
 type Context struct {
 	InstanceName string
+	BaseURL      string
 
 	Username      string
 	User          *User
 	Authenticated bool
+	CSRFToken     string
+	IsAdmin       bool
 
 	Error   bool
 	Message string
 
+	Form       map[string]string
+	FormErrors map[string]string
+
+	Token string
+
+	TOTPSecret    string
+	TOTPURI       string
+	TOTPQRCode    template.URL
+	RecoveryCodes []string
+
 	Tweeter Tweeter
 	Tweets  Tweets
 
+	Profile Profile
+
+	Query   string
+	Users   []*User
+	Invites []*Invite
+	Drafts  []*Draft
+
+	Mentions       []*Mention
+	Feeds          []*KnownFeed
+	UnreadMentions int
+
+	Sessions    []*session.Session
+	SessionHash string
+
+	Identities []*Identity
+
+	// Reauthenticated is whether a user without a password has confirmed it
+	// is them for a sensitive action
+	Reauthenticated bool
+
+	OIDCEnabled bool
+	OIDCName    string
+
 	RegisterDisabled        bool
 	RegisterDisabledMessage string
+	InviteOnly              bool
 }
 
 func NewContext(conf *Config, db Store, req *http.Request) *Context {
 	ctx := &Context{
 		InstanceName: conf.Name,
+		BaseURL:      strings.TrimSuffix(conf.BaseURL, "/"),
 
-		RegisterDisabled: !conf.Register,
+		RegisterDisabled: !conf.Register && !conf.InviteOnly,
+		InviteOnly:       conf.InviteOnly,
+
+		OIDCEnabled: conf.OIDCEnabled(),
+		OIDCName:    conf.OIDCName,
 	}
 
 	if sess := req.Context().Value("sesssion"); sess != nil {
@@ -37,12 +80,13 @@ func NewContext(conf *Config, db Store, req *http.Request) *Context {
 			ctx.Authenticated = true
 			ctx.Username = username
 		}
+		ctx.CSRFToken, _ = sess.(*session.Session).Get(csrfKey)
 	}
 
 	if ctx.Authenticated && ctx.Username != "" {
 		ctx.Tweeter = Tweeter{
 			Nick: ctx.Username,
-			URL:  fmt.Sprintf("http://0.0.0.0:8000/u/%s", ctx.Username),
+			URL:  URLForUser(conf.BaseURL, ctx.Username),
 		}
 
 		user, err := db.GetUser(ctx.Username)
@@ -50,6 +94,16 @@ func NewContext(conf *Config, db Store, req *http.Request) *Context {
 			log.WithError(err).Warnf("error loading user object for %s", ctx.Username)
 		}
 		ctx.User = user
+
+		if user != nil && user.Admin {
+			ctx.IsAdmin = true
+		}
+
+		unread, err := UnreadMentions(db, ctx.Username)
+		if err != nil {
+			log.WithError(err).Warnf("error counting unread mentions of %s", ctx.Username)
+		}
+		ctx.UnreadMentions = unread
 	}
 
 	return ctx
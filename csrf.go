@@ -0,0 +1,91 @@
+package twtxt
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/prologic/twtxt/session"
+)
+
+const (
+	csrfKey    = "csrf"
+	csrfField  = "csrf_token"
+	csrfHeader = "X-CSRF-Token"
+)
+
+// csrfExemptPaths are path prefixes of endpoints meant to be called by
+// other servers and clients rather than from our own forms
+var csrfExemptPaths = []string{"/api/plain/", "/webmention"}
+
+func csrfSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+func csrfExempt(path string) bool {
+	for _, prefix := range csrfExemptPaths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// CSRFHandler ensures every session has a CSRF token and rejects state
+// changing requests that do not present it either as a form field or header
+func (s *Server) CSRFHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sess, ok := r.Context().Value("sesssion").(*session.Session)
+		if !ok {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		token, ok := sess.Get(csrfKey)
+		if !ok {
+			var err error
+			token, err = GenerateRandomToken(32)
+			if err != nil {
+				log.WithError(err).Error("error generating csrf token")
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			sess.Set(csrfKey, token)
+		}
+
+		if csrfSafeMethod(r.Method) || csrfExempt(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		given := r.Header.Get(csrfHeader)
+		if given == "" {
+			given = r.FormValue(csrfField)
+		}
+
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			log.WithFields(log.Fields{
+				"method": r.Method,
+				"path":   r.URL.Path,
+				"remote": r.RemoteAddr,
+			}).Warn("csrf token mismatch")
+
+			ctx := &Context{
+				Error:   true,
+				Message: "Your form has expired or was submitted from another site, please go back, reload and try again",
+			}
+			w.WriteHeader(http.StatusForbidden)
+			s.render("error", w, ctx)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
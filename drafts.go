@@ -0,0 +1,216 @@
+package twtxt
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// MaxUserDrafts is the number of drafts and scheduled tweets a user may
+	// have at any one time
+	MaxUserDrafts = 50
+
+	// MissedScheduleGracePeriod is how late a scheduled tweet is still
+	// published, e.g: when the server was down at the scheduled time. Tweets
+	// that are later than this are unscheduled and kept as drafts instead so
+	// their authors can decide whether they are still relevant.
+	MissedScheduleGracePeriod = 24 * time.Hour
+
+	// ScheduleTimeLayout is the layout of schedule times submitted by the
+	// datetime-local inputs of forms, which are interpreted as UTC
+	ScheduleTimeLayout = "2006-01-02T15:04"
+)
+
+var (
+	ErrTooManyDrafts       = fmt.Errorf("you may have at most %d drafts and scheduled tweets", MaxUserDrafts)
+	ErrInvalidScheduleTime = errors.New("error: schedule time must be a valid time in the future")
+
+	errAuthorDisabled = errors.New("error: author of draft is disabled")
+)
+
+// draftsMu serializes saving and publishing of drafts so that a draft
+// published by the scheduler and its author at the same time is only
+// published once, and one being published is not saved again
+var draftsMu sync.Mutex
+
+// ParseScheduleTime parses a schedule time submitted by a form, which must be
+// later than now
+func ParseScheduleTime(value string, now time.Time) (time.Time, error) {
+	t, err := time.ParseInLocation(ScheduleTimeLayout, value, time.UTC)
+	if err != nil || !t.After(now) {
+		return time.Time{}, ErrInvalidScheduleTime
+	}
+	return t, nil
+}
+
+// SaveDraft creates or updates the draft, assigning it an id if it is new.
+// ErrDraftNotFound is returned when updating a draft that has since been
+// published or deleted.
+func SaveDraft(db Store, draft *Draft) error {
+	draftsMu.Lock()
+	defer draftsMu.Unlock()
+
+	if draft.ID != "" {
+		if _, err := db.GetDraft(draft.ID); err != nil {
+			return err
+		}
+	} else {
+		drafts, err := GetUserDrafts(db, draft.Username)
+		if err != nil {
+			return err
+		}
+		if len(drafts) >= MaxUserDrafts {
+			return ErrTooManyDrafts
+		}
+
+		id, err := GenerateRandomToken(12)
+		if err != nil {
+			return err
+		}
+		draft.ID = id
+		draft.CreatedAt = time.Now()
+	}
+
+	return db.SetDraft(draft.ID, draft)
+}
+
+// GetUserDrafts returns the drafts of the given user, scheduled tweets first
+// in the order they will be published
+func GetUserDrafts(db Store, username string) ([]*Draft, error) {
+	drafts, err := db.GetAllDrafts()
+	if err != nil {
+		return nil, err
+	}
+
+	var userDrafts []*Draft
+	for _, draft := range drafts {
+		if draft.Username == username {
+			userDrafts = append(userDrafts, draft)
+		}
+	}
+
+	sort.Slice(userDrafts, func(i, j int) bool {
+		a, b := userDrafts[i], userDrafts[j]
+		if a.Scheduled() != b.Scheduled() {
+			return a.Scheduled()
+		}
+		if a.Scheduled() {
+			return a.PublishAt.Before(b.PublishAt)
+		}
+		return a.CreatedAt.After(b.CreatedAt)
+	})
+
+	return userDrafts, nil
+}
+
+// DeleteUserDrafts removes all drafts of the given user
+func DeleteUserDrafts(db Store, username string) error {
+	drafts, err := GetUserDrafts(db, username)
+	if err != nil {
+		return err
+	}
+
+	for _, draft := range drafts {
+		if err := db.DelDraft(draft.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PublishDraft appends the draft with the given id to its author's feed and
+// removes it
+func PublishDraft(conf *Config, db Store, id string) error {
+	draftsMu.Lock()
+	defer draftsMu.Unlock()
+
+	return publishDraft(conf, db, id)
+}
+
+func publishDraft(conf *Config, db Store, id string) error {
+	// Reload the draft in case it was published or deleted in the meantime
+	draft, err := db.GetDraft(id)
+	if err != nil {
+		return err
+	}
+
+	user, err := db.GetUser(draft.Username)
+	if err != nil {
+		return err
+	}
+	if user.Disabled {
+		return errAuthorDisabled
+	}
+
+	tweet, err := AppendTweet(conf, resolveMentions(conf, db, user, draft.Text), user)
+	if err != nil {
+		return err
+	}
+
+	if _, err := DeliverMentions(conf, db, Tweets{tweet}, time.Now()); err != nil {
+		log.WithError(err).Warnf("error delivering mentions of draft %s", id)
+	}
+	go SendWebmentions(conf, tweet)
+
+	return db.DelDraft(id)
+}
+
+// PublishDueDrafts publishes all scheduled drafts whose time has come as of
+// now, and unschedules those that were missed by more than the
+// MissedScheduleGracePeriod. It returns the number of published drafts.
+func PublishDueDrafts(conf *Config, db Store, now time.Time) (int, error) {
+	draftsMu.Lock()
+	defer draftsMu.Unlock()
+
+	drafts, err := db.GetAllDrafts()
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, draft := range drafts {
+		if !draft.Scheduled() || draft.PublishAt.After(now) {
+			continue
+		}
+
+		if now.Sub(draft.PublishAt) > MissedScheduleGracePeriod {
+			log.Warnf("missed scheduled draft %s of %s by %s", draft.ID, draft.Username, now.Sub(draft.PublishAt))
+			draft.Error = fmt.Sprintf("Not published as it was missed by more than %s", MissedScheduleGracePeriod)
+			draft.PublishAt = time.Time{}
+			if err := db.SetDraft(draft.ID, draft); err != nil {
+				log.WithError(err).Errorf("error unscheduling draft %s", draft.ID)
+			}
+			continue
+		}
+
+		if err := publishDraft(conf, db, draft.ID); err != nil {
+			switch err {
+			case errAuthorDisabled:
+				// Keep it until the author is enabled again
+				continue
+			case ErrUserNotFound:
+				if err := db.DelDraft(draft.ID); err != nil {
+					log.WithError(err).Errorf("error deleting orphaned draft %s", draft.ID)
+				}
+				continue
+			}
+
+			log.WithError(err).Errorf("error publishing draft %s of %s", draft.ID, draft.Username)
+			draft.Error = "Error publishing, will retry"
+			if err := db.SetDraft(draft.ID, draft); err != nil {
+				log.WithError(err).Errorf("error updating draft %s", draft.ID)
+			}
+			continue
+		}
+
+		published++
+	}
+
+	return published, nil
+}
@@ -0,0 +1,102 @@
+package twtxt
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPublishDueDrafts(t *testing.T) {
+	conf, user, fn := setupFeed(t, "")
+	defer os.RemoveAll(conf.Data)
+
+	db, _ := newTestStore(t)
+
+	if err := db.SetUser(user.Username, user); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2020, 7, 20, 12, 0, 0, 0, time.UTC)
+
+	drafts := map[string]*Draft{
+		"draft":  {Username: "alice", Text: "draft"},
+		"due":    {Username: "alice", Text: "due", PublishAt: now.Add(-time.Minute)},
+		"late":   {Username: "alice", Text: "late", PublishAt: now.Add(-MissedScheduleGracePeriod + time.Hour)},
+		"missed": {Username: "alice", Text: "missed", PublishAt: now.Add(-MissedScheduleGracePeriod - time.Hour)},
+		"future": {Username: "alice", Text: "future", PublishAt: now.Add(time.Hour)},
+		"orphan": {Username: "bob", Text: "orphan", PublishAt: now.Add(-time.Minute)},
+	}
+	for _, draft := range drafts {
+		if err := SaveDraft(db, draft); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := PublishDueDrafts(conf, db, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 published drafts got %d", n)
+	}
+
+	data, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"due", "late"} {
+		if !strings.Contains(string(data), "\t"+text+"\n") {
+			t.Errorf("expected %q to be published in:\n%s", text, data)
+		}
+	}
+
+	for name, expected := range map[string]bool{
+		"draft":  true,
+		"due":    false,
+		"late":   false,
+		"missed": true,
+		"future": true,
+		"orphan": false,
+	} {
+		draft, err := db.GetDraft(drafts[name].ID)
+		if exists := err == nil; exists != expected {
+			t.Errorf("expected draft %q to exist: %t got error %v", name, expected, err)
+			continue
+		}
+
+		if name == "missed" && (draft.Scheduled() || draft.Error == "") {
+			t.Errorf("expected missed draft to be unscheduled with an error got %+v", draft)
+		}
+		if name == "future" && !draft.Scheduled() {
+			t.Errorf("expected future draft to remain scheduled")
+		}
+	}
+
+	// Saving a draft that was published meanwhile must not bring it back
+	if err := SaveDraft(db, drafts["due"]); err != ErrDraftNotFound {
+		t.Errorf("expected ErrDraftNotFound saving a published draft got %v", err)
+	}
+	if _, err := db.GetDraft(drafts["due"].ID); err != ErrDraftNotFound {
+		t.Errorf("expected published draft to stay gone got %v", err)
+	}
+}
+
+func TestParseScheduleTime(t *testing.T) {
+	now := time.Date(2020, 7, 20, 12, 0, 0, 0, time.UTC)
+
+	actual, err := ParseScheduleTime("2020-07-20T13:30", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := now.Add(90 * time.Minute); !actual.Equal(expected) {
+		t.Errorf("expected %s got %s", expected, actual)
+	}
+
+	for _, value := range []string{"", "tomorrow", "2020-07-20T11:59", "2020-07-20T12:00"} {
+		if _, err := ParseScheduleTime(value, now); err != ErrInvalidScheduleTime {
+			t.Errorf("expected ErrInvalidScheduleTime for %q got %v", value, err)
+		}
+	}
+}
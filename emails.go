@@ -0,0 +1,65 @@
+package twtxt
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	verifyTokenTTL = 48 * time.Hour
+	resetTokenTTL  = 1 * time.Hour
+)
+
+func formatTTL(ttl time.Duration) string {
+	if hours := int(ttl.Hours()); hours != 1 {
+		return fmt.Sprintf("%d hours", hours)
+	}
+	return "hour"
+}
+
+func (s *Server) tokenURL(path, token string) string {
+	return fmt.Sprintf(
+		"%s%s?token=%s",
+		strings.TrimSuffix(s.config.BaseURL, "/"), path, url.QueryEscape(token),
+	)
+}
+
+func (s *Server) sendVerificationEmail(user *User) error {
+	token, err := IssueToken(s.db, s.config.Secret, TokenVerify, user, verifyTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"Hi %s,\r\n\r\n"+
+			"Please confirm your email address for your twtxt account on %s\r\n"+
+			"by visiting the following link within the next %s:\r\n\r\n"+
+			"%s\r\n\r\n"+
+			"If you did not sign up, you can safely ignore this email.\r\n",
+		user.Username, s.config.Name, formatTTL(verifyTokenTTL),
+		s.tokenURL("/verify", token),
+	)
+
+	return s.mailer.Send(user.Email, "Please verify your email address", body)
+}
+
+func (s *Server) sendPasswordResetEmail(user *User) error {
+	token, err := IssueToken(s.db, s.config.Secret, TokenReset, user, resetTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"Hi %s,\r\n\r\n"+
+			"Someone requested a password reset for your twtxt account on %s.\r\n"+
+			"To choose a new password visit the following link within the next %s:\r\n\r\n"+
+			"%s\r\n\r\n"+
+			"If you did not request this, you can safely ignore this email.\r\n",
+		user.Username, s.config.Name, formatTTL(resetTokenTTL),
+		s.tokenURL("/reset", token),
+	)
+
+	return s.mailer.Send(user.Email, "Reset your password", body)
+}
@@ -0,0 +1,121 @@
+package twtxt
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// Profile is the public representation of a user's account, shown on their
+// profile page and exported along with their email address
+type Profile struct {
+	Username    string    `json:"username"`
+	Email       string    `json:"email,omitempty"`
+	URL         string    `json:"url"`
+	DisplayName string    `json:"display_name,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Homepage    string    `json:"homepage,omitempty"`
+	AvatarURL   string    `json:"avatar_url,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// NewProfile returns the public profile of a user
+func NewProfile(conf *Config, user *User) Profile {
+	profile := Profile{
+		Username:    user.Username,
+		URL:         URLForUser(conf.BaseURL, user.Username),
+		DisplayName: user.DisplayName,
+		Description: user.Description,
+		Homepage:    user.Homepage,
+		CreatedAt:   user.CreatedAt,
+	}
+
+	if user.Avatar != "" {
+		profile.AvatarURL = URLForAvatar(conf.BaseURL, user.Username, user.Avatar)
+	}
+
+	return profile
+}
+
+// Name returns the display name of the user, or their username if unset
+func (p Profile) Name() string {
+	if p.DisplayName != "" {
+		return p.DisplayName
+	}
+	return p.Username
+}
+
+// ExportUser writes a zip archive of the user's feed, following list and
+// profile to w
+func ExportUser(conf *Config, user *User, w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	profile := NewProfile(conf, user)
+	profile.Email = user.Email
+	if err := writeJSON(zw, "profile.json", profile); err != nil {
+		return err
+	}
+
+	if err := writeJSON(zw, "following.json", user.Following); err != nil {
+		return err
+	}
+
+	feed, err := OpenFeed(conf.Data, user.Username)
+	if err != nil {
+		return err
+	}
+
+	data, _, err := feed.Read()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		fw, err := zw.Create("twtxt.txt")
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(data); err != nil {
+			return err
+		}
+	}
+
+	fn, err := avatarPath(conf.Data, user.Username)
+	if err != nil {
+		return err
+	}
+
+	avatar, err := os.Open(fn)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		defer avatar.Close()
+
+		fw, err := zw.Create("avatar.png")
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(fw, avatar); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func writeJSON(zw *zip.Writer, name string, v interface{}) error {
+	fw, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fw.Write(data)
+	return err
+}
@@ -0,0 +1,159 @@
+package twtxt
+
+import (
+	"hash/fnv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+)
+
+// feedLockStripes is the number of locks shared by all feed files
+const feedLockStripes = 256
+
+// feedLocks guard the local feed files, each file always uses the same lock
+// picked by a hash of its path so that all goroutines share it without
+// keeping state for every path ever opened
+var feedLocks [feedLockStripes]sync.RWMutex
+
+// feedsVersion is bumped on every change to a local feed, so that tweets
+// merged from all of them can be reused until one changes
+var feedsVersion uint64
+
+// Feed guards a local user's feed file. There is only ever a single writer
+// per feed, every write is synced to disk before it returns, and readers
+// never observe a partially written tweet.
+type Feed struct {
+	mu *sync.RWMutex
+	fn string
+}
+
+// OpenFeed returns the Feed of the given user's feed file in path
+func OpenFeed(path, username string) (*Feed, error) {
+	fn, err := securejoin.SecureJoin(filepath.Join(path, feedsDir), username)
+	if err != nil {
+		return nil, err
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(fn))
+
+	return &Feed{mu: &feedLocks[h.Sum32()%feedLockStripes], fn: fn}, nil
+}
+
+// Path returns the path of the feed file
+func (feed *Feed) Path() string {
+	return feed.fn
+}
+
+// Read returns the contents and the file info of the feed, or
+// os.ErrNotExist if it does not exist yet
+func (feed *Feed) Read() ([]byte, os.FileInfo, error) {
+	feed.mu.RLock()
+	defer feed.mu.RUnlock()
+
+	f, err := os.Open(feed.fn)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, stat, nil
+}
+
+// Append appends data to the feed in a single write, creating the feed if it
+// does not exist yet
+func (feed *Feed) Append(data []byte) error {
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+	defer atomic.AddUint64(&feedsVersion, 1)
+
+	if err := os.MkdirAll(filepath.Dir(feed.fn), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(feed.fn, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Update atomically replaces the contents of the feed by the result of
+// update, which is called with the current contents, or nil if the feed does
+// not exist yet. The feed's modification time always moves forward by at
+// least a second so that clients relying on Last-Modified notice the change.
+func (feed *Feed) Update(update func(data []byte) ([]byte, error)) error {
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+	defer atomic.AddUint64(&feedsVersion, 1)
+
+	if err := os.MkdirAll(filepath.Dir(feed.fn), 0755); err != nil {
+		return err
+	}
+
+	var modified time.Time
+
+	data, err := ioutil.ReadFile(feed.fn)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		stat, err := os.Stat(feed.fn)
+		if err != nil {
+			return err
+		}
+		modified = stat.ModTime().Truncate(time.Second).Add(time.Second)
+	}
+
+	data, err = update(data)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(feed.fn, data, 0644); err != nil {
+		return err
+	}
+
+	if now := time.Now(); now.After(modified) {
+		modified = now
+	}
+	return os.Chtimes(feed.fn, modified, modified)
+}
+
+// Remove deletes the feed file, if any
+func (feed *Feed) Remove() error {
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+	defer atomic.AddUint64(&feedsVersion, 1)
+
+	if err := os.Remove(feed.fn); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package twtxt
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestOpenFeed(t *testing.T) {
+	a, err := OpenFeed("/data", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := OpenFeed("/data", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.mu != b.mu {
+		t.Error("expected the same lock to be shared")
+	}
+
+	c, err := OpenFeed("/data", "../../etc/passwd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(c.Path(), "/data/feeds/") {
+		t.Errorf("expected feed to be confined to the feeds directory got %s", c.Path())
+	}
+}
+
+func TestConcurrentFeedWrites(t *testing.T) {
+	conf, user, fn := setupFeed(t, "")
+	defer os.RemoveAll(conf.Data)
+
+	const (
+		writers = 8
+		tweets  = 25
+	)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < tweets; j++ {
+				text := fmt.Sprintf("tweet %d-%d %s", i, j, strings.Repeat("x", 100))
+				if _, err := AppendTweet(conf, text, user); err != nil {
+					t.Error(err)
+				}
+			}
+		}(i)
+	}
+
+	// Rewrite the header while tweets are being posted
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < tweets; j++ {
+			if err := WriteFeedMetadata(conf, user); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	// Readers must only ever see whole tweets
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				tweets, err := GetUserTweets(conf, user.Username)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				for _, tweet := range tweets {
+					if !strings.HasSuffix(tweet.Text, strings.Repeat("x", 100)) {
+						t.Errorf("read partial tweet %q", tweet.Text)
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(done)
+	readers.Wait()
+
+	feed, err := OpenFeed(conf.Data, user.Username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _, err := feed.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.Path() != fn {
+		t.Errorf("expected feed path %s got %s", fn, feed.Path())
+	}
+
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, "\t", 2)
+		if len(fields) != 2 {
+			t.Fatalf("malformed line %q", line)
+		}
+		if seen[fields[1]] {
+			t.Errorf("duplicate tweet %q", fields[1])
+		}
+		seen[fields[1]] = true
+	}
+
+	if len(seen) != writers*tweets {
+		t.Errorf("expected %d tweets got %d", writers*tweets, len(seen))
+	}
+}
@@ -0,0 +1,59 @@
+package twtxt
+
+import (
+	"os"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type FileMailer struct {
+	sync.Mutex
+
+	path string
+	from string
+}
+
+func newFileMailer(path, from string) (*FileMailer, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileMailer{path: path, from: from}, f.Close()
+}
+
+func (m *FileMailer) Send(to, subject, body string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	f, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(formatEmail(m.from, to, subject, body)); err != nil {
+		return err
+	}
+
+	_, err = f.WriteString("\r\n")
+	return err
+}
+
+type LogMailer struct {
+	from string
+}
+
+func newLogMailer(from string) *LogMailer {
+	return &LogMailer{from: from}
+}
+
+func (m *LogMailer) Send(to, subject, body string) error {
+	log.WithFields(log.Fields{
+		"from":    m.from,
+		"to":      to,
+		"subject": subject,
+	}).Info(body)
+	return nil
+}
@@ -0,0 +1,243 @@
+package twtxt
+
+import (
+	"fmt"
+	"html/template"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tweetTokens matches the parts of a tweet that are rendered as markup, in
+// order of precedence: mentions, Markdown images and links, bare URLs,
+// hashtags, inline code, bold and italic text
+var tweetTokens = regexp.MustCompile(
+	`(?P<mention>@<(?:(?P<mentionnick>[^ <>]+) +)?(?P<mentionurl>[^ <>]+)>)` +
+		`|(?P<image>!\[(?P<imagealt>[^\[\]]*)\]\((?P<imageurl>[^ ()]+)\))` +
+		`|(?P<link>\[(?P<linktext>[^\[\]]+)\]\((?P<linkurl>[^ ()]+)\))` +
+		`|(?P<url>(?i:https?)://[^\s<>"]+)` +
+		`|(?P<tag>#[-\w]+)` +
+		"|(?P<code>`(?P<codetext>[^`]+)`)" +
+		`|(?P<bold>\*\*(?P<boldtext>[^*]+)\*\*)` +
+		`|(?P<italic>(?:\*(?P<italictext>[^*\s][^*]*)\*|\b_(?P<underscoretext>[^_\s][^_]*)_\b))`,
+)
+
+var tweetTokenGroups = func() map[string]int {
+	groups := make(map[string]int)
+	for i, name := range tweetTokens.SubexpNames() {
+		groups[name] = i
+	}
+	return groups
+}()
+
+var mentionPattern = regexp.MustCompile(`@<(?:([^ <>]+) +)?([^ <>]+)>`)
+
+var tagPattern = regexp.MustCompile(`#[-\w]+`)
+
+// atWordStart reports whether the text at i starts a word, which tags must
+// do so that e.g. `issue#1` is not tagged 1
+func atWordStart(text string, i int) bool {
+	r, _ := utf8.DecodeLastRuneInString(text[:i])
+	return i == 0 || !(unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// safeURL returns the normalized form of the given URL if it is an absolute
+// http(s) URL that is safe to link to
+func safeURL(rawurl string) (string, bool) {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+	default:
+		return "", false
+	}
+
+	return u.String(), true
+}
+
+// trimURL removes trailing punctuation that is more likely part of the
+// sentence than of a bare URL, e.g: "see https://example.com."
+func trimURL(rawurl string) (string, string) {
+	trimmed := strings.TrimRightFunc(rawurl, func(r rune) bool {
+		return strings.ContainsRune(".,:;!?'", r)
+	})
+
+	// Keep closing parentheses that balance ones in the URL
+	for strings.HasSuffix(trimmed, ")") && strings.Count(trimmed, "(") < strings.Count(trimmed, ")") {
+		trimmed = strings.TrimSuffix(trimmed, ")")
+	}
+
+	return trimmed, rawurl[len(trimmed):]
+}
+
+// localMedia returns the name of the image if the URL refers to an image
+// uploaded to this instance
+func localMedia(baseURL, rawurl string) (string, bool) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return "", false
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil || !strings.EqualFold(u.Host, base.Host) {
+		return "", false
+	}
+
+	dir, name := path.Split(u.Path)
+	if dir != strings.TrimSuffix(base.Path, "/")+"/media/" || !ValidMediaName(name) {
+		return "", false
+	}
+
+	return name, true
+}
+
+func link(href, text string) string {
+	return fmt.Sprintf(
+		`<a href="%s" rel="nofollow noopener noreferrer">%s</a>`,
+		template.HTMLEscapeString(href), text,
+	)
+}
+
+// formatMention renders a mention of nick at the given URL as a link, or
+// returns false if the URL is not a http(s) URL that is safe to link to
+func formatMention(nick, rawurl string) (string, bool) {
+	href, ok := safeURL(rawurl)
+	if !ok {
+		return "", false
+	}
+	if nick == "" {
+		nick = href
+	}
+	return link(href, "@"+template.HTMLEscapeString(nick)), true
+}
+
+// FormatTweet renders the text of a tweet as HTML. All text is escaped and
+// only the following markup is produced from it:
+//
+//   - mentions `@<nick URL>` link to URL
+//   - Markdown images `![alt](URL)` show a thumbnail if they are uploaded to
+//     this instance and link to URL otherwise
+//   - Markdown links `[text](URL)` and bare URLs link to URL
+//   - hashtags `#tag` link to the tag's page
+//   - `code`, **bold** and *italic* or _italic_ text
+//
+// Only http(s) URLs are linked, anything else is left as text.
+func FormatTweet(baseURL, text string) template.HTML {
+	return template.HTML(formatTweet(baseURL, text))
+}
+
+func formatTweet(baseURL, text string) string {
+	var sb strings.Builder
+
+	group := func(m []int, name string) string {
+		i := tweetTokenGroups[name]
+		if m[2*i] < 0 {
+			return ""
+		}
+		return text[m[2*i]:m[2*i+1]]
+	}
+
+	last := 0
+	for _, m := range tweetTokens.FindAllStringSubmatchIndex(text, -1) {
+		start, end := m[0], m[1]
+
+		var html string
+		switch {
+		case group(m, "mention") != "":
+			var ok bool
+			html, ok = formatMention(group(m, "mentionnick"), group(m, "mentionurl"))
+			if !ok {
+				continue
+			}
+
+		case group(m, "image") != "":
+			href, ok := safeURL(group(m, "imageurl"))
+			if !ok {
+				continue
+			}
+			alt := group(m, "imagealt")
+			if name, ok := localMedia(baseURL, href); ok {
+				html = link(href, fmt.Sprintf(
+					`<img src="%s" alt="%s" loading="lazy">`,
+					template.HTMLEscapeString(URLForMedia(baseURL, ThumbnailName(name))),
+					template.HTMLEscapeString(alt),
+				))
+			} else {
+				if alt == "" {
+					alt = href
+				}
+				html = link(href, template.HTMLEscapeString(alt))
+			}
+
+		case group(m, "link") != "":
+			href, ok := safeURL(group(m, "linkurl"))
+			if !ok {
+				continue
+			}
+			html = link(href, template.HTMLEscapeString(group(m, "linktext")))
+
+		case group(m, "url") != "":
+			rawurl, rest := trimURL(group(m, "url"))
+			href, ok := safeURL(rawurl)
+			if !ok {
+				continue
+			}
+			end -= len(rest)
+			html = link(href, template.HTMLEscapeString(rawurl))
+
+		case group(m, "tag") != "":
+			if !atWordStart(text, start) {
+				continue
+			}
+			tag := strings.TrimPrefix(group(m, "tag"), "#")
+			html = fmt.Sprintf(`<a href="/tag/%s">#%s</a>`, url.PathEscape(tag), template.HTMLEscapeString(tag))
+
+		case group(m, "code") != "":
+			html = "<code>" + template.HTMLEscapeString(group(m, "codetext")) + "</code>"
+
+		case group(m, "bold") != "":
+			html = "<strong>" + formatTweet(baseURL, group(m, "boldtext")) + "</strong>"
+
+		case group(m, "italic") != "":
+			inner := group(m, "italictext") + group(m, "underscoretext")
+			html = "<em>" + formatTweet(baseURL, inner) + "</em>"
+
+		default:
+			continue
+		}
+
+		sb.WriteString(template.HTMLEscapeString(text[last:start]))
+		sb.WriteString(html)
+		last = end
+	}
+	sb.WriteString(template.HTMLEscapeString(text[last:]))
+
+	return sb.String()
+}
+
+// Tags returns the hashtags of the tweet without their leading #
+func (tweet Tweet) Tags() []string {
+	var tags []string
+	for _, m := range tagPattern.FindAllStringIndex(tweet.Text, -1) {
+		if atWordStart(tweet.Text, m[0]) {
+			tags = append(tags, tweet.Text[m[0]+1:m[1]])
+		}
+	}
+	return tags
+}
+
+// HasTag reports whether the tweet is tagged with the given tag, ignoring
+// case
+func (tweet Tweet) HasTag(tag string) bool {
+	for _, t := range tweet.Tags() {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+package twtxt
+
+import (
+	"testing"
+)
+
+func TestFormatTweet(t *testing.T) {
+	const baseURL = "https://example.com"
+
+	testCases := []struct {
+		text     string
+		expected string
+	}{
+		{
+			"hello world",
+			"hello world",
+		},
+		{
+			"hi @<bob https://bob.example.com/twtxt.txt>!",
+			`hi <a href="https://bob.example.com/twtxt.txt" rel="nofollow noopener noreferrer">@bob</a>!`,
+		},
+		{
+			"@<http://bob.example.com/twtxt.txt>",
+			`<a href="http://bob.example.com/twtxt.txt" rel="nofollow noopener noreferrer">@http://bob.example.com/twtxt.txt</a>`,
+		},
+		{
+			`@<"bob' https://example.com>`,
+			`<a href="https://example.com" rel="nofollow noopener noreferrer">@&#34;bob&#39;</a>`,
+		},
+		{
+			`@<bob https://example.com/"onmouseover="alert(1)>`,
+			`<a href="https://example.com/%22onmouseover=%22alert%281%29" rel="nofollow noopener noreferrer">@bob</a>`,
+		},
+		{
+			"see https://example.org/a_(b)?c=1&d=2.",
+			`see <a href="https://example.org/a_(b)?c=1&amp;d=2" rel="nofollow noopener noreferrer">https://example.org/a_(b)?c=1&amp;d=2</a>.`,
+		},
+		{
+			"(https://example.org/x)",
+			`(<a href="https://example.org/x" rel="nofollow noopener noreferrer">https://example.org/x</a>)`,
+		},
+		{
+			"read [the docs](https://example.org/docs)",
+			`read <a href="https://example.org/docs" rel="nofollow noopener noreferrer">the docs</a>`,
+		},
+		{
+			"#twtxt is great, issue#1 is not a tag",
+			`<a href="/tag/twtxt">#twtxt</a> is great, issue#1 is not a tag`,
+		},
+		{
+			"**bold** *italic* _also italic_ `a*b*c` snake_case_name",
+			`<strong>bold</strong> <em>italic</em> <em>also italic</em> <code>a*b*c</code> snake_case_name`,
+		},
+		{
+			"**see https://example.org**",
+			`<strong>see <a href="https://example.org" rel="nofollow noopener noreferrer">https://example.org</a></strong>`,
+		},
+		{
+			"![a cat](https://example.com/media/0123456789abcdef0123456789abcdef.jpg)",
+			`<a href="https://example.com/media/0123456789abcdef0123456789abcdef.jpg" rel="nofollow noopener noreferrer">` +
+				`<img src="https://example.com/media/0123456789abcdef0123456789abcdef-thumb.jpg" alt="a cat" loading="lazy"></a>`,
+		},
+		{
+			"![a cat](https://elsewhere.example.org/cat.jpg)",
+			`<a href="https://elsewhere.example.org/cat.jpg" rel="nofollow noopener noreferrer">a cat</a>`,
+		},
+	}
+
+	for _, tc := range testCases {
+		if actual := string(FormatTweet(baseURL, tc.text)); actual != tc.expected {
+			t.Errorf("formatting %q\nexpected: %s\ngot:      %s", tc.text, tc.expected, actual)
+		}
+	}
+}
+
+func TestFormatTweetXSS(t *testing.T) {
+	testCases := []struct {
+		text     string
+		expected string
+	}{
+		{
+			`<script>alert(1)</script>`,
+			`&lt;script&gt;alert(1)&lt;/script&gt;`,
+		},
+		{
+			`<img src=x onerror=alert(1)>`,
+			`&lt;img src=x onerror=alert(1)&gt;`,
+		},
+		{
+			`[click](javascript:alert(1))`,
+			`[click](javascript:alert(1))`,
+		},
+		{
+			`[click](JaVaScRiPt:alert(1))`,
+			`[click](JaVaScRiPt:alert(1))`,
+		},
+		{
+			`[click](data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==)`,
+			`[click](data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==)`,
+		},
+		{
+			`![x](javascript:alert(1))`,
+			`![x](javascript:alert(1))`,
+		},
+		{
+			`![" onerror="alert(1)](https://example.com/media/0123456789abcdef0123456789abcdef.jpg)`,
+			`<a href="https://example.com/media/0123456789abcdef0123456789abcdef.jpg" rel="nofollow noopener noreferrer"><img src="https://example.com/media/0123456789abcdef0123456789abcdef-thumb.jpg" alt="&#34; onerror=&#34;alert(1)" loading="lazy"></a>`,
+		},
+		{
+			`[<b onmouseover=alert(1)>hi</b>](https://example.org)`,
+			`<a href="https://example.org" rel="nofollow noopener noreferrer">&lt;b onmouseover=alert(1)&gt;hi&lt;/b&gt;</a>`,
+		},
+		{
+			`https://example.org/"onmouseover="alert(1)`,
+			`<a href="https://example.org/" rel="nofollow noopener noreferrer">https://example.org/</a>&#34;onmouseover=&#34;alert(1)`,
+		},
+		{
+			`https://example.org/<script>alert(1)</script>`,
+			`<a href="https://example.org/" rel="nofollow noopener noreferrer">https://example.org/</a>&lt;script&gt;alert(1)&lt;/script&gt;`,
+		},
+		{
+			`@<bob javascript:alert(1)>`,
+			`@&lt;bob javascript:alert(1)&gt;`,
+		},
+		{
+			`@<bob JaVaScRiPt:alert(1)>`,
+			`@&lt;bob JaVaScRiPt:alert(1)&gt;`,
+		},
+		{
+			`@<bob data:text/html,<script>alert(1)</script>>`,
+			`@&lt;bob data:text/html,&lt;script&gt;alert(1)&lt;/script&gt;&gt;`,
+		},
+		{
+			`@<bob //evil.example.com>`,
+			`@&lt;bob //evil.example.com&gt;`,
+		},
+		{
+			`@<<b>bob</b> https://example.com>`,
+			`@&lt;&lt;b&gt;bob&lt;/b&gt; <a href="https://example.com" rel="nofollow noopener noreferrer">https://example.com</a>&gt;`,
+		},
+		{
+			`@<bob https://example.com><script>alert(1)</script>`,
+			`<a href="https://example.com" rel="nofollow noopener noreferrer">@bob</a>&lt;script&gt;alert(1)&lt;/script&gt;`,
+		},
+		{
+			`@<"><script>alert(1)</script> https://example.org>`,
+			`@&lt;&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt; <a href="https://example.org" rel="nofollow noopener noreferrer">https://example.org</a>&gt;`,
+		},
+		{
+			`#<script>alert(1)</script>`,
+			`#&lt;script&gt;alert(1)&lt;/script&gt;`,
+		},
+		{
+			"`</code><script>alert(1)</script>`",
+			`<code>&lt;/code&gt;&lt;script&gt;alert(1)&lt;/script&gt;</code>`,
+		},
+		{
+			`**<script>alert(1)</script>**`,
+			`<strong>&lt;script&gt;alert(1)&lt;/script&gt;</strong>`,
+		},
+		{
+			`*<svg onload=alert(1)>*`,
+			`<em>&lt;svg onload=alert(1)&gt;</em>`,
+		},
+	}
+
+	for _, tc := range testCases {
+		if actual := string(FormatTweet("https://example.com", tc.text)); actual != tc.expected {
+			t.Errorf("formatting %q\nexpected: %s\ngot:      %s", tc.text, tc.expected, actual)
+		}
+	}
+}
+
+func TestTweetHasTag(t *testing.T) {
+	tweet := Tweet{Text: "hello #TwTxt and #go-lang"}
+	if !tweet.HasTag("twtxt") || !tweet.HasTag("go-lang") || tweet.HasTag("go") {
+		t.Errorf("unexpected tags %v", tweet.Tags())
+	}
+
+	tweet = Tweet{Text: "fixes issue#1, see (#bug)"}
+	if tweet.HasTag("1") || !tweet.HasTag("bug") {
+		t.Errorf("unexpected tags %v", tweet.Tags())
+	}
+}
@@ -8,19 +8,26 @@ require (
 	github.com/andreadipersio/securecookie v0.0.0-20131119095127-e3c3b33544ec
 	github.com/asdine/storm v2.1.2+incompatible
 	github.com/aws/aws-sdk-go v1.33.7
+	github.com/coreos/go-oidc v2.2.1+incompatible
 	github.com/cyphar/filepath-securejoin v0.2.2
 	github.com/dustin/go-humanize v1.0.0
 	github.com/elithrar/simple-scrypt v1.3.0
 	github.com/goware/urlx v0.3.1
 	github.com/julienschmidt/httprouter v1.3.0
 	github.com/patrickmn/go-cache v2.1.0+incompatible
+	github.com/pquerna/cachecontrol v0.2.0 // indirect
 	github.com/prologic/bitcask v0.3.5
 	github.com/rcrowley/go-metrics v0.0.0-20200313005456-10cdbea86bc0
 	github.com/robfig/cron v1.2.0
 	github.com/schollz/progressbar/v3 v3.3.4
 	github.com/sirupsen/logrus v1.6.0
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	github.com/spf13/pflag v1.0.5
 	github.com/thoas/stats v0.0.0-20190407194641-965cb2de1678
 	github.com/unrolled/logger v0.0.0-20190327162521-be1a2406c7c9
+	golang.org/x/crypto v0.0.0-20190510104115-cbcb75029529
+	golang.org/x/image v0.0.0-20200618115811-c13761719519
+	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
+	gopkg.in/square/go-jose.v2 v2.6.0
 	honnef.co/go/tools v0.0.0-20190102054323-c2f93a96b099
 )
@@ -2,20 +2,22 @@ package twtxt
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
-	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
-	securejoin "github.com/cyphar/filepath-securejoin"
 	"github.com/julienschmidt/httprouter"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/prologic/twtxt/session"
+	"github.com/prologic/twtxt/totp"
 )
 
 func (s *Server) NotFoundHandler(w http.ResponseWriter, r *http.Request) {
@@ -41,18 +43,29 @@ func (s *Server) TwtxtHandler() httprouter.Handle {
 			return
 		}
 
-		path, err := securejoin.SecureJoin(filepath.Join(s.config.Data, "feeds"), nick)
+		if _, err := s.db.GetUser(nick); err != nil {
+			http.Error(w, "Feed Not Found", http.StatusNotFound)
+			return
+		}
+
+		feed, err := OpenFeed(s.config.Data, nick)
 		if err != nil {
 			http.Error(w, "Bad Request", http.StatusBadRequest)
 			return
 		}
 
-		stat, err := os.Stat(path)
+		data, stat, err := feed.Read()
 		if err != nil {
+			if os.IsNotExist(err) {
+				http.Error(w, "Feed Not Found", http.StatusNotFound)
+				return
+			}
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
 
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="webmention"`, URLForWebmention(s.config.BaseURL)))
+
 		if r.Method == http.MethodHead {
 			defer r.Body.Close()
 			w.Header().Set("Content-Type", "text/plain")
@@ -65,19 +78,132 @@ func (s *Server) TwtxtHandler() httprouter.Handle {
 				stat.ModTime().UTC().Format(http.TimeFormat),
 			)
 		} else if r.Method == http.MethodGet {
-			http.ServeFile(w, r, path)
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			http.ServeContent(w, r, "", stat.ModTime(), bytes.NewReader(data))
 		} else {
 			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 		}
 	}
 }
 
+// ProfileHandler ...
+func (s *Server) ProfileHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		user, err := s.db.GetUser(p.ByName("nick"))
+		if err != nil || user.Disabled {
+			s.NotFoundHandler(w, r)
+			return
+		}
+
+		tweets, err := GetUserTweets(s.config, user.Username)
+		if err != nil {
+			log.WithError(err).Errorf("error loading tweets for %s", user.Username)
+		}
+
+		sort.Sort(sort.Reverse(tweets))
+
+		if len(tweets) > 50 {
+			tweets = tweets[:50]
+		}
+
+		ctx.Profile = NewProfile(s.config, user)
+		ctx.Tweets = tweets
+
+		s.render("profile", w, ctx)
+	}
+}
+
+// AvatarHandler ...
+func (s *Server) AvatarHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		fn, err := avatarPath(s.config.Data, p.ByName("nick"))
+		if err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		f, err := os.Open(fn)
+		if err != nil {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		defer f.Close()
+
+		stat, err := f.Stat()
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		http.ServeContent(w, r, "avatar.png", stat.ModTime(), f)
+	}
+}
+
+// MediaHandler serves uploaded images and their thumbnails, which never
+// change as they are named after their contents
+func (s *Server) MediaHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		name := p.ByName("name")
+		if !ValidMediaName(name) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+
+		fn, err := mediaPath(s.config.Data, name)
+		if err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		f, err := os.Open(fn)
+		if err != nil {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		defer f.Close()
+
+		stat, err := f.Stat()
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		http.ServeContent(w, r, name, stat.ModTime(), f)
+	}
+}
+
 // PostHandler ...
 func (s *Server) PostHandler() httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		ctx := NewContext(s.config, s.db, r)
 
 		text := r.FormValue("text")
+
+		file, _, err := r.FormFile("media")
+		if err == nil {
+			defer file.Close()
+
+			name, err := SaveMedia(s.config.Data, file)
+			if err != nil {
+				log.WithError(err).Warnf("error saving media for %s", ctx.Username)
+				ctx := &Context{
+					Error:   true,
+					Message: fmt.Sprintf("Error posting tweet: %s", ErrInvalidMedia),
+				}
+				s.render("error", w, ctx)
+				return
+			}
+			text = strings.TrimSpace(text + " " + MediaLink(s.config.BaseURL, name, r.FormValue("alt")))
+		} else if err != http.ErrMissingFile && err != http.ErrNotMultipart {
+			log.WithError(err).Warn("error reading media upload")
+		}
+
 		if text == "" {
 			ctx := &Context{
 				Error:   true,
@@ -98,7 +224,148 @@ func (s *Server) PostHandler() httprouter.Handle {
 			return
 		}
 
-		if err := AppendTweet(s.config.Data, text, user); err != nil {
+		switch r.FormValue("action") {
+		case "draft", "schedule":
+			draft := &Draft{Username: user.Username, Text: text}
+			if r.FormValue("action") == "schedule" {
+				publishAt, err := ParseScheduleTime(r.FormValue("publish_at"), time.Now())
+				if err != nil {
+					ctx := &Context{
+						Error:   true,
+						Message: "Please choose a time in the future to schedule your tweet",
+					}
+					s.render("error", w, ctx)
+					return
+				}
+				draft.PublishAt = publishAt
+			}
+
+			if err := SaveDraft(s.db, draft); err != nil {
+				s.renderDraftError(w, user.Username, err)
+				return
+			}
+
+			http.Redirect(w, r, "/drafts", http.StatusFound)
+			return
+		}
+
+		tweet, err := AppendTweet(s.config, resolveMentions(s.config, s.db, user, text), user)
+		if err != nil {
+			ctx := &Context{
+				Error:   true,
+				Message: "Error posting tweet",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		if _, err := DeliverMentions(s.config, s.db, Tweets{tweet}, time.Now()); err != nil {
+			log.WithError(err).Warnf("error delivering mentions of tweet by %s", user.Username)
+		}
+		go SendWebmentions(s.config, tweet)
+
+		http.Redirect(w, r, "/", http.StatusFound)
+	}
+}
+
+func (s *Server) renderDraftError(w http.ResponseWriter, username string, err error) {
+	message := "Error saving draft"
+	if err == ErrTooManyDrafts {
+		message = fmt.Sprintf("You may have at most %d drafts and scheduled tweets", MaxUserDrafts)
+	} else if err == ErrDraftNotFound {
+		message = "The draft has already been published or deleted"
+	} else {
+		log.WithError(err).Errorf("error saving draft of %s", username)
+	}
+
+	ctx := &Context{
+		Error:   true,
+		Message: message,
+	}
+	s.render("error", w, ctx)
+}
+
+// DraftsHandler ...
+func (s *Server) DraftsHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		if r.Method == "GET" {
+			drafts, err := GetUserDrafts(s.db, ctx.Username)
+			if err != nil {
+				log.WithError(err).Errorf("error loading drafts of %s", ctx.Username)
+				ctx := &Context{
+					Error:   true,
+					Message: "Error loading drafts",
+				}
+				s.render("error", w, ctx)
+				return
+			}
+
+			ctx.Drafts = drafts
+			s.render("drafts", w, ctx)
+			return
+		}
+
+		draft, ok := s.loadUserDraft(w, r, ctx.Username)
+		if !ok {
+			return
+		}
+
+		text := r.FormValue("text")
+		if text == "" {
+			ctx := &Context{
+				Error:   true,
+				Message: "No post content provided!",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+		draft.Text = text
+		draft.Error = ""
+
+		switch r.FormValue("action") {
+		case "schedule":
+			publishAt, err := ParseScheduleTime(r.FormValue("publish_at"), time.Now())
+			if err != nil {
+				ctx := &Context{
+					Error:   true,
+					Message: "Please choose a time in the future to schedule your tweet",
+				}
+				s.render("error", w, ctx)
+				return
+			}
+			draft.PublishAt = publishAt
+		case "unschedule":
+			draft.PublishAt = time.Time{}
+		}
+
+		if err := SaveDraft(s.db, draft); err != nil {
+			s.renderDraftError(w, ctx.Username, err)
+			return
+		}
+
+		http.Redirect(w, r, "/drafts", http.StatusFound)
+	}
+}
+
+// PublishDraftHandler ...
+func (s *Server) PublishDraftHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		draft, ok := s.loadUserDraft(w, r, ctx.Username)
+		if !ok {
+			return
+		}
+
+		if err := PublishDraft(s.config, s.db, draft.ID); err != nil {
+			if err == ErrDraftNotFound {
+				// Already published by the scheduler
+				http.Redirect(w, r, "/", http.StatusFound)
+				return
+			}
+			log.WithError(err).Errorf("error publishing draft %s of %s", draft.ID, ctx.Username)
 			ctx := &Context{
 				Error:   true,
 				Message: "Error posting tweet",
@@ -111,6 +378,144 @@ func (s *Server) PostHandler() httprouter.Handle {
 	}
 }
 
+// DeleteDraftHandler ...
+func (s *Server) DeleteDraftHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		draft, ok := s.loadUserDraft(w, r, ctx.Username)
+		if !ok {
+			return
+		}
+
+		if err := s.db.DelDraft(draft.ID); err != nil {
+			log.WithError(err).Errorf("error deleting draft %s of %s", draft.ID, ctx.Username)
+			ctx := &Context{
+				Error:   true,
+				Message: "Error deleting draft",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		http.Redirect(w, r, "/drafts", http.StatusFound)
+	}
+}
+
+// loadUserDraft loads the draft identified by the submitted id, rendering the
+// not found page if it does not exist or belongs to someone else
+func (s *Server) loadUserDraft(w http.ResponseWriter, r *http.Request, username string) (*Draft, bool) {
+	draft, err := s.db.GetDraft(r.FormValue("id"))
+	if err == nil && draft.Username != username {
+		err = ErrDraftNotFound
+	}
+
+	switch err {
+	case nil:
+		return draft, true
+	case ErrDraftNotFound:
+		s.NotFoundHandler(w, r)
+	default:
+		log.WithError(err).Errorf("error loading draft of %s", username)
+		ctx := &Context{
+			Error:   true,
+			Message: "Error loading draft",
+		}
+		s.render("error", w, ctx)
+	}
+
+	return nil, false
+}
+
+// EditTweetHandler ...
+func (s *Server) EditTweetHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		user := ctx.User
+		if user == nil {
+			log.Fatalf("user not found in context")
+		}
+
+		hash := p.ByName("hash")
+
+		if r.Method == "GET" {
+			tweets, err := GetUserTweets(s.config, user.Username)
+			if err != nil {
+				log.WithError(err).Errorf("error loading tweets for %s", user.Username)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			for _, tweet := range tweets {
+				if tweet.Hash() == hash {
+					ctx.Tweets = Tweets{tweet}
+					ctx.Form = map[string]string{"text": CollapseMentions(tweet.Text)}
+					s.render("edit", w, ctx)
+					return
+				}
+			}
+
+			s.NotFoundHandler(w, r)
+			return
+		}
+
+		text := resolveMentions(s.config, s.db, user, r.FormValue("text"))
+		old, edited, err := EditTweet(s.config, user, hash, text)
+		if err != nil {
+			if err == ErrTweetNotFound {
+				s.NotFoundHandler(w, r)
+				return
+			}
+			log.WithError(err).Errorf("error editing tweet %s of %s", hash, user.Username)
+			ctx := &Context{
+				Error:   true,
+				Message: "Error editing tweet",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		if _, err := RedeliverMentions(s.config, s.db, old, edited, time.Now()); err != nil {
+			log.WithError(err).Warnf("error delivering mentions of tweet by %s", user.Username)
+		}
+		go SendWebmentions(s.config, edited)
+
+		http.Redirect(w, r, URLForProfile("", user.Username), http.StatusFound)
+	}
+}
+
+// DeleteTweetHandler ...
+func (s *Server) DeleteTweetHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		user := ctx.User
+		if user == nil {
+			log.Fatalf("user not found in context")
+		}
+
+		hash := p.ByName("hash")
+		tombstone := r.FormValue("tombstone") != ""
+
+		if err := DeleteTweet(s.config, user, hash, tombstone); err != nil {
+			if err == ErrTweetNotFound {
+				s.NotFoundHandler(w, r)
+				return
+			}
+			log.WithError(err).Errorf("error deleting tweet %s of %s", hash, user.Username)
+			ctx := &Context{
+				Error:   true,
+				Message: "Error deleting tweet",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		http.Redirect(w, r, URLForProfile("", user.Username), http.StatusFound)
+	}
+}
+
 // TimelineHandler ...
 func (s *Server) TimelineHandler() httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
@@ -157,328 +562,1815 @@ func (s *Server) TimelineHandler() httprouter.Handle {
 	}
 }
 
-// LoginHandler ...
-func (s *Server) LoginHandler() httprouter.Handle {
+// LookupHandler returns the feeds whose nick starts with the query as JSON,
+// to suggest them while writing a mention
+func (s *Server) LookupHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		user := ctx.User
+		if user == nil {
+			log.Fatalf("user not found in context")
+		}
+
+		cache, err := SharedCache(s.config.Data)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		suggestions, err := LookupMentions(s.config, s.db, cache, user, strings.TrimPrefix(r.FormValue("q"), "@"))
+		if err != nil {
+			log.WithError(err).Error("error looking up mentions")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if suggestions == nil {
+			suggestions = []MentionSuggestion{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(suggestions); err != nil {
+			log.WithError(err).Error("error encoding mention suggestions")
+		}
+	}
+}
+
+// MentionsHandler shows the user's mentions inbox and marks them as read
+func (s *Server) MentionsHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		mentions, err := GetUserMentions(s.db, ctx.Username)
+		if err != nil {
+			log.WithError(err).Errorf("error loading mentions of %s", ctx.Username)
+			ctx := &Context{
+				Error:   true,
+				Message: "Error loading mentions",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+		ctx.Mentions = mentions
+
+		if err := MarkMentionsRead(s.db, ctx.Username); err != nil {
+			log.WithError(err).Warnf("error marking mentions of %s as read", ctx.Username)
+		}
+		ctx.UnreadMentions = 0
+
+		s.render("mentions", w, ctx)
+	}
+}
+
+// DiscoverHandler shows the directory of feeds in the registry
+func (s *Server) DiscoverHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		ctx.Query = strings.TrimSpace(r.FormValue("q"))
+
+		feeds, err := SearchRegistry(s.db, ctx.Query)
+		if err != nil {
+			log.WithError(err).Error("error searching registry")
+			ctx := &Context{
+				Error:   true,
+				Message: "Error loading the directory",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		if len(feeds) > 100 {
+			ctx.Feeds = feeds[:100]
+		} else {
+			ctx.Feeds = feeds
+		}
+
+		s.render("discover", w, ctx)
+	}
+}
+
+// TagHandler lists the latest tweets of local and followed feeds tagged with
+// the given tag
+func (s *Server) TagHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		tag := p.ByName("tag")
+
+		tweets, err := GetAllTweets(s.config)
+		if err != nil {
+			ctx := &Context{
+				Error:   true,
+				Message: "An error occurred while loading tweets",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		cache, err := LoadCache(s.config.Data)
+		if err != nil {
+			log.WithError(err).Warn("error loading cache")
+		} else {
+			tweets = append(tweets, cache.GetAll()...)
+		}
+
+		seen := make(map[string]bool)
+		var tagged Tweets
+		for _, tweet := range tweets {
+			if !tweet.HasTag(tag) || seen[tweet.Hash()] {
+				continue
+			}
+			seen[tweet.Hash()] = true
+			tagged = append(tagged, tweet)
+		}
+
+		sort.Sort(sort.Reverse(tagged))
+
+		if len(tagged) > 50 {
+			ctx.Tweets = tagged[:50]
+		} else {
+			ctx.Tweets = tagged
+		}
+		ctx.Query = tag
+
+		s.render("tag", w, ctx)
+	}
+}
+
+// LoginHandler ...
+func (s *Server) LoginHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		if r.Method == "GET" {
+			s.render("login", w, ctx)
+			return
+		}
+
+		username := r.FormValue("username")
+		password := r.FormValue("password")
+		remember := r.FormValue("remember") != ""
+
+		// Error: no username or password provided
+		if username == "" || password == "" {
+			log.Warn("no username or password provided")
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+
+		ip := RemoteIP(r)
+
+		if s.rateLimited(w, r, s.ipLimiter, ip) || s.rateLimited(w, r, s.userLimiter, username) {
+			return
+		}
+
+		loginFailed := func() {
+			SecurityEvent(r, "login-failed", username)
+			if s.ipLimiter.Hit(ip) {
+				SecurityEvent(r, "lockout-ip", username)
+			}
+			if s.userLimiter.Hit(username) {
+				SecurityEvent(r, "lockout-user", username)
+			}
+		}
+
+		// Lookup user
+		user, err := s.db.GetUser(username)
+		if err != nil {
+			log.WithError(err).Errorf("error looking up user %s", username)
+			loginFailed()
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+
+		if user.Disabled {
+			log.Warnf("login attempt for disabled user %s", username)
+			ctx := &Context{
+				Error:   true,
+				Message: "Your account has been disabled. Please contact the operator.",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		// Validate cleartext password against KDF hash
+		err = s.pm.Check(user.Password, password)
+		if err != nil {
+			log.WithError(err).Errorf("password mismatch for %s", username)
+			loginFailed()
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+
+		// Upgrade the hash if it uses an outdated algorithm or parameters
+		if s.pm.NeedsRehash(user.Password) {
+			if hash, err := s.pm.NewPassword(password); err != nil {
+				log.WithError(err).Errorf("error rehashing password for %s", username)
+			} else {
+				user.Password = hash
+				if err := s.db.SetUser(username, user); err != nil {
+					log.WithError(err).Errorf("error updating user %s", username)
+				} else {
+					log.Infof("rehashed password for %s", username)
+				}
+			}
+		}
+
+		// Lookup session
+		sess, ok := r.Context().Value("sesssion").(*session.Session)
+		if !ok {
+			log.Warn("no session found")
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+
+		// Require a second factor before authorizing the session
+		if user.TOTPSecret != "" {
+			log.Infof("password accepted, awaiting second factor: %s", username)
+			sess.Set("2fa_pending", username)
+			if remember {
+				sess.Set("2fa_remember", "1")
+			}
+			http.Redirect(w, r, "/login/2fa", http.StatusFound)
+			return
+		}
+
+		// Login successful
+		log.Infof("login successful: %s", username)
+		s.userLimiter.Reset(username)
+
+		// Authorize session
+		if _, err := s.authorize(w, sess, username, remember); err != nil {
+			log.WithError(err).Errorf("error authorizing session for %s", username)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/", http.StatusFound)
+	}
+}
+
+// LoginTwoFactorHandler ...
+func (s *Server) LoginTwoFactorHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		sess, ok := r.Context().Value("sesssion").(*session.Session)
+		if !ok {
+			log.Warn("no session found")
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+
+		username, ok := sess.Get("2fa_pending")
+		if !ok || username == "" {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+
+		if r.Method == "GET" {
+			s.render("2fa", w, ctx)
+			return
+		}
+
+		ip := RemoteIP(r)
+
+		if s.rateLimited(w, r, s.ipLimiter, ip) || s.rateLimited(w, r, s.userLimiter, username) {
+			return
+		}
+
+		user, err := s.db.GetUser(username)
+		if err != nil || user.Disabled {
+			log.WithError(err).Errorf("error looking up user %s", username)
+			sess.Delete("2fa_pending")
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+
+		code := strings.TrimSpace(r.FormValue("code"))
+
+		if !CheckTOTP(user, code) && !UseRecoveryCode(user, code) {
+			SecurityEvent(r, "2fa-failed", username)
+			if s.ipLimiter.Hit(ip) {
+				SecurityEvent(r, "lockout-ip", username)
+			}
+			if s.userLimiter.Hit(username) {
+				SecurityEvent(r, "lockout-user", username)
+			}
+			ctx.FormErrors = map[string]string{"code": "Invalid authentication or recovery code"}
+			w.WriteHeader(http.StatusUnauthorized)
+			s.render("2fa", w, ctx)
+			return
+		}
+
+		if err := s.db.SetUser(username, user); err != nil {
+			log.WithError(err).Errorf("error updating user %s", username)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		// Login successful
+		log.Infof("login successful: %s", username)
+		s.userLimiter.Reset(username)
+
+		// Authorize session
+		_, remember := sess.Get("2fa_remember")
+		sess.Delete("2fa_pending")
+		sess.Delete("2fa_remember")
+
+		if _, err := s.authorize(w, sess, username, remember); err != nil {
+			log.WithError(err).Errorf("error authorizing session for %s", username)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/", http.StatusFound)
+	}
+}
+
+// OIDCLoginHandler redirects the user to the OpenID Connect provider to log
+// in, or to link their identity there if they are already logged in
+func (s *Server) OIDCLoginHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		if s.oidc == nil {
+			s.NotFoundHandler(w, r)
+			return
+		}
+
+		sess, ok := r.Context().Value("sesssion").(*session.Session)
+		if !ok {
+			log.Warn("no session found")
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+
+		state, err := GenerateRandomToken(32)
+		if err != nil {
+			log.WithError(err).Error("error generating oidc state")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		nonce, err := GenerateRandomToken(32)
+		if err != nil {
+			log.WithError(err).Error("error generating oidc nonce")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		sess.Set("oidc_state", state)
+		sess.Set("oidc_nonce", nonce)
+
+		// Have a logged in user without a password login again to confirm a
+		// sensitive action, sending them back to it afterwards
+		if next := r.FormValue("reauth"); next != "" {
+			if _, ok := sess.Get("username"); !ok || !reauthPaths[next] {
+				http.Redirect(w, r, "/login", http.StatusFound)
+				return
+			}
+			sess.Set("oidc_reauth", next)
+			http.Redirect(w, r, s.oidc.ReauthCodeURL(state, nonce), http.StatusFound)
+			return
+		}
+		if _, ok := sess.Get("oidc_reauth"); ok {
+			sess.Delete("oidc_reauth")
+		}
+
+		http.Redirect(w, r, s.oidc.AuthCodeURL(state, nonce), http.StatusFound)
+	}
+}
+
+// OIDCCallbackHandler completes logging in with the OpenID Connect provider.
+// The identity is linked to the logged in user if there is one, otherwise the
+// user it is linked to is logged in, creating them if auto-provisioning is
+// enabled.
+func (s *Server) OIDCCallbackHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		if s.oidc == nil {
+			s.NotFoundHandler(w, r)
+			return
+		}
+
+		sess, ok := r.Context().Value("sesssion").(*session.Session)
+		if !ok {
+			log.Warn("no session found")
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+
+		state, _ := sess.Get("oidc_state")
+		nonce, _ := sess.Get("oidc_nonce")
+		reauth, _ := sess.Get("oidc_reauth")
+		sess.Delete("oidc_state")
+		sess.Delete("oidc_nonce")
+		sess.Delete("oidc_reauth")
+
+		if state == "" || r.FormValue("state") != state {
+			log.Warn("oidc callback with invalid state")
+			ctx := &Context{
+				Error:   true,
+				Message: fmt.Sprintf("Your %s login has expired, please try again", s.config.OIDCName),
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		if e := r.FormValue("error"); e != "" {
+			log.Warnf("oidc provider returned error %s: %s", e, r.FormValue("error_description"))
+			ctx := &Context{
+				Error:   true,
+				Message: fmt.Sprintf("Login with %s failed or was cancelled", s.config.OIDCName),
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		identity, err := s.oidc.Exchange(r.Context(), r.FormValue("code"), nonce)
+		if err != nil {
+			log.WithError(err).Error("error completing oidc login")
+			ctx := &Context{
+				Error:   true,
+				Message: fmt.Sprintf("Login with %s failed", s.config.OIDCName),
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		link, err := s.db.GetIdentity(identityKey(identity.Issuer, identity.Subject))
+		if err != nil && err != ErrIdentityNotFound {
+			log.WithError(err).Error("error looking up identity")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		// Confirm it is the logged in user for a sensitive action
+		if reauth != "" {
+			if !ctx.Authenticated || link == nil || link.Username != ctx.Username ||
+				time.Since(identity.AuthTime) > reauthWindow {
+				log.Warnf("oidc reauthentication failed for %s", ctx.Username)
+				ctx := &Context{
+					Error:   true,
+					Message: fmt.Sprintf("Unable to confirm it is you with %s, please try again", s.config.OIDCName),
+				}
+				s.render("error", w, ctx)
+				return
+			}
+
+			setReauthenticated(sess, ctx.Username)
+			http.Redirect(w, r, reauth, http.StatusFound)
+			return
+		}
+
+		// Link the identity to the logged in user
+		if ctx.Authenticated {
+			if link != nil && link.Username != ctx.Username {
+				ctx := &Context{
+					Error:   true,
+					Message: fmt.Sprintf("Your %s account is already linked to another user", s.config.OIDCName),
+				}
+				s.render("error", w, ctx)
+				return
+			}
+
+			if link == nil {
+				if err := LinkIdentity(s.db, identity, ctx.Username); err != nil {
+					log.WithError(err).Errorf("error linking identity to %s", ctx.Username)
+					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+					return
+				}
+				AuditLog(s.config.Data, ctx.Username, "link-identity", identity.Issuer)
+			}
+
+			http.Redirect(w, r, "/settings", http.StatusFound)
+			return
+		}
+
+		var user *User
+		if link != nil {
+			user, err = s.db.GetUser(link.Username)
+			if err != nil {
+				log.WithError(err).Errorf("error loading user %s for identity", link.Username)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+		} else if s.config.OIDCAutoProvision {
+			user, err = s.provisionUser(identity)
+			if err != nil {
+				log.WithError(err).Warnf("error provisioning user %q", identity.Username)
+				ctx := &Context{
+					Error: true,
+					Message: fmt.Sprintf(
+						"Unable to create an account for %q: %s. "+
+							"If you already have an account, login and link your %s account from your settings.",
+						identity.Username, err, s.config.OIDCName,
+					),
+				}
+				s.render("error", w, ctx)
+				return
+			}
+			log.Infof("user provisioned: %s", user.Username)
+		} else {
+			ctx := &Context{
+				Error: true,
+				Message: fmt.Sprintf(
+					"No account is linked to your %s account. "+
+						"Login and link it from your settings first.",
+					s.config.OIDCName,
+				),
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		if user.Disabled {
+			log.Warnf("login attempt for disabled user %s", user.Username)
+			ctx := &Context{
+				Error:   true,
+				Message: "Your account has been disabled. Please contact the operator.",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		// Require a second factor before authorizing the session
+		if user.TOTPSecret != "" {
+			log.Infof("oidc login accepted, awaiting second factor: %s", user.Username)
+			sess.Set("2fa_pending", user.Username)
+			http.Redirect(w, r, "/login/2fa", http.StatusFound)
+			return
+		}
+
+		log.Infof("login successful: %s (via %s)", user.Username, identity.Issuer)
+
+		if _, err := s.authorize(w, sess, user.Username, false); err != nil {
+			log.WithError(err).Errorf("error authorizing session for %s", user.Username)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/", http.StatusFound)
+	}
+}
+
+// LogoutHandler ...
+func (s *Server) LogoutHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		s.sm.Delete(w, r)
+		http.Redirect(w, r, "/", http.StatusFound)
+	}
+}
+
+// RegisterHandler ...
+func (s *Server) RegisterHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		if !s.config.Register && !s.config.InviteOnly {
+			message := s.config.RegisterMessage
+
+			if message == "" {
+				message = "Registrations are disabled on this instance. Please contact the operator."
+			}
+
+			ctx := &Context{
+				Error:   true,
+				Message: message,
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		if r.Method == "GET" {
+			ctx.Form = map[string]string{
+				"invite": r.FormValue("invite"),
+			}
+			s.render("register", w, ctx)
+			return
+		}
+
+		ip := RemoteIP(r)
+
+		if s.rateLimited(w, r, s.requestLimiter, ip) {
+			return
+		}
+		if s.requestLimiter.Hit(ip) {
+			SecurityEvent(r, "lockout-register", r.FormValue("username"))
+		}
+
+		username := strings.TrimSpace(r.FormValue("username"))
+		password := r.FormValue("password")
+		email := strings.TrimSpace(r.FormValue("email"))
+		code := strings.TrimSpace(r.FormValue("invite"))
+
+		ctx.Form = map[string]string{
+			"username": username,
+			"email":    email,
+			"invite":   code,
+		}
+		ctx.FormErrors = make(map[string]string)
+
+		if s.config.InviteOnly {
+			if invite, err := s.db.GetInvite(code); err != nil || !invite.Valid() {
+				ctx.FormErrors["invite"] = ErrInvalidInvite.Error()
+			}
+		}
+
+		if err := ValidateUsername(username); err != nil {
+			ctx.FormErrors["username"] = err.Error()
+		} else if exists, err := UsernameExists(s.db, username); err != nil {
+			log.WithError(err).Error("error checking for existing user")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		} else if exists {
+			ctx.FormErrors["username"] = ErrUsernameTaken.Error()
+		}
+
+		if err := ValidateEmail(email); err != nil {
+			ctx.FormErrors["email"] = err.Error()
+		}
+
+		if err := ValidatePassword(username, password); err != nil {
+			ctx.FormErrors["password"] = err.Error()
+		}
+
+		if len(ctx.FormErrors) > 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			s.render("register", w, ctx)
+			return
+		}
+
+		hash, err := s.pm.NewPassword(password)
+		if err != nil {
+			log.WithError(err).Error("error creating password hash")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		user := &User{
+			Username:  username,
+			Email:     email,
+			Password:  hash,
+			CreatedAt: time.Now(),
+		}
+
+		if s.config.InviteOnly {
+			invite, err := RedeemInvite(s.db, code, username)
+			if err != nil {
+				ctx.FormErrors["invite"] = ErrInvalidInvite.Error()
+				w.WriteHeader(http.StatusBadRequest)
+				s.render("register", w, ctx)
+				return
+			}
+			user.InvitedBy = invite.CreatedBy
+		}
+
+		if err := s.db.SetUser(username, user); err != nil {
+			log.WithError(err).Errorf("error creating user %s", username)
+			ctx := &Context{
+				Error:   true,
+				Message: "Error creating your account",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		if user.Email != "" {
+			if err := s.sendVerificationEmail(user); err != nil {
+				log.WithError(err).Errorf("error sending verification email to %s", username)
+			}
+		}
+
+		log.Infof("user registered: %s", username)
+		http.Redirect(w, r, "/login", http.StatusFound)
+	}
+}
+
+// VerifyHandler ...
+func (s *Server) VerifyHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		token, err := RedeemToken(s.db, s.config.Secret, TokenVerify, r.FormValue("token"))
+		if err != nil {
+			ctx := &Context{
+				Error:   true,
+				Message: "Your verification link is invalid or has expired",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		user, err := s.db.GetUser(token.Username)
+		if err != nil || user.Email != token.Email {
+			ctx := &Context{
+				Error:   true,
+				Message: "Your verification link is invalid or has expired",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		user.Verified = true
+
+		if err := s.db.SetUser(user.Username, user); err != nil {
+			log.WithError(err).Errorf("error updating user %s", user.Username)
+			ctx := &Context{
+				Error:   true,
+				Message: "Error verifying your email address",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		ctx := &Context{
+			Error:   false,
+			Message: "Thank you! Your email address has been verified",
+		}
+		s.render("error", w, ctx)
+	}
+}
+
+// ResendVerificationHandler ...
+func (s *Server) ResendVerificationHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		user := ctx.User
+		if user == nil {
+			log.Fatalf("user not found in context")
+		}
+
+		if user.Email == "" || user.Verified {
+			ctx := &Context{
+				Error:   true,
+				Message: "There is no unverified email address on your account",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		if err := s.sendVerificationEmail(user); err != nil {
+			log.WithError(err).Errorf("error sending verification email to %s", user.Username)
+			ctx := &Context{
+				Error:   true,
+				Message: "Error sending verification email",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		ctx = &Context{
+			Error:   false,
+			Message: fmt.Sprintf("A verification link has been sent to %s", user.Email),
+		}
+		s.render("error", w, ctx)
+	}
+}
+
+// ForgotPasswordHandler ...
+func (s *Server) ForgotPasswordHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		if r.Method == "GET" {
+			s.render("forgot", w, ctx)
+			return
+		}
+
+		username := strings.TrimSpace(r.FormValue("username"))
+		ip := RemoteIP(r)
+
+		if s.rateLimited(w, r, s.requestLimiter, ip, "forgot:"+username) {
+			return
+		}
+		if s.requestLimiter.Hit(ip) {
+			SecurityEvent(r, "lockout-forgot", username)
+		}
+		s.requestLimiter.Hit("forgot:" + username)
+
+		user, err := s.db.GetUser(username)
+		if err != nil && strings.Contains(username, "@") {
+			users, err := s.db.GetAllUsers()
+			if err != nil {
+				log.WithError(err).Error("error loading users")
+			}
+			for _, u := range users {
+				if strings.EqualFold(u.Email, username) {
+					user = u
+					break
+				}
+			}
+		}
+
+		if user != nil && user.Email != "" && !user.Disabled {
+			if err := s.sendPasswordResetEmail(user); err != nil {
+				log.WithError(err).Errorf("error sending password reset email to %s", user.Username)
+			}
+		} else {
+			log.Warnf("password reset requested for unknown or unreachable user %s", username)
+		}
+
+		// Always respond the same way so as not to disclose which accounts exist
+		ctx = &Context{
+			Error:   false,
+			Message: "If an account with an email address matches, a password reset link has been sent to it",
+		}
+		s.render("error", w, ctx)
+	}
+}
+
+// ResetPasswordHandler ...
+func (s *Server) ResetPasswordHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		value := r.FormValue("token")
+		ip := RemoteIP(r)
+
+		if s.rateLimited(w, r, s.ipLimiter, ip) {
+			return
+		}
+
+		token, err := LookupToken(s.db, s.config.Secret, TokenReset, value)
+		if err != nil {
+			SecurityEvent(r, "invalid-reset-token", "")
+			if s.ipLimiter.Hit(ip) {
+				SecurityEvent(r, "lockout-ip", "")
+			}
+			ctx := &Context{
+				Error:   true,
+				Message: "Your password reset link is invalid or has expired",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		if r.Method == "GET" {
+			ctx.Token = value
+			s.render("reset", w, ctx)
+			return
+		}
+
+		user, err := s.db.GetUser(token.Username)
+		if err != nil {
+			ctx := &Context{
+				Error:   true,
+				Message: "Your password reset link is invalid or has expired",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		password := r.FormValue("password")
+		if err := ValidatePassword(user.Username, password); err != nil {
+			ctx.Token = value
+			ctx.FormErrors = map[string]string{"password": err.Error()}
+			w.WriteHeader(http.StatusBadRequest)
+			s.render("reset", w, ctx)
+			return
+		}
+
+		if _, err := RedeemToken(s.db, s.config.Secret, TokenReset, value); err != nil {
+			ctx := &Context{
+				Error:   true,
+				Message: "Your password reset link is invalid or has expired",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		hash, err := s.pm.NewPassword(password)
+		if err != nil {
+			log.WithError(err).Error("error creating password hash")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		user.Password = hash
+
+		if err := s.db.SetUser(user.Username, user); err != nil {
+			log.WithError(err).Errorf("error updating user %s", user.Username)
+			ctx := &Context{
+				Error:   true,
+				Message: "Error resetting your password",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		if err := s.sm.Purge("username", user.Username); err != nil {
+			log.WithError(err).Errorf("error purging sessions for %s", user.Username)
+		}
+
+		log.Infof("password reset for %s", user.Username)
+
+		ctx = &Context{
+			Error:   false,
+			Message: "Your password has been reset, you may now login",
+		}
+		s.render("error", w, ctx)
+	}
+}
+
+// FollowHandler ...
+func (s *Server) FollowHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		if r.Method == "GET" {
+			s.render("follow", w, ctx)
+			return
+		}
+
+		nick := strings.TrimSpace(r.FormValue("nick"))
+		url := strings.TrimSpace(r.FormValue("url"))
+
+		if nick == "" || url == "" {
+			ctx := &Context{
+				Error:   true,
+				Message: "Both nick and url must be specified",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		user := ctx.User
+		if user == nil {
+			log.Fatalf("user not found in context")
+		}
+
+		user.Following[nick] = url
+
+		if err := s.db.SetUser(ctx.Username, user); err != nil {
+			ctx := &Context{
+				Error:   true,
+				Message: fmt.Sprintf("Error following feed %s: %s", nick, url),
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		ctx = &Context{
+			Error:   false,
+			Message: fmt.Sprintf("Successfully started following %s: %s", nick, url),
+		}
+		s.render("error", w, ctx)
+		return
+	}
+}
+
+// ImportHandler ...
+func (s *Server) ImportHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		if r.Method == "GET" {
+			s.render("import", w, ctx)
+			return
+		}
+
+		feeds := r.FormValue("feeds")
+
+		if feeds == "" {
+			ctx := &Context{
+				Error:   true,
+				Message: "Nothing to import!",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		user := ctx.User
+		if user == nil {
+			log.Fatalf("user not found in context")
+		}
+
+		re := regexp.MustCompile(`(?P<nick>.*?)[: ](?P<url>.*)`)
+
+		imported := 0
+
+		scanner := bufio.NewScanner(strings.NewReader(feeds))
+		for scanner.Scan() {
+			line := scanner.Text()
+			matches := re.FindStringSubmatch(line)
+			if len(matches) == 3 {
+				nick := strings.TrimSpace(matches[1])
+				url := NormalizeURL(strings.TrimSpace(matches[2]))
+				if nick != "" && url != "" {
+					user.Following[nick] = url
+					imported++
+				}
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			log.WithError(err).Error("error scanning feeds for import")
+			ctx := &Context{
+				Error:   true,
+				Message: "Error importing feeds",
+			}
+			s.render("error", w, ctx)
+		}
+
+		if err := s.db.SetUser(ctx.Username, user); err != nil {
+			ctx := &Context{
+				Error:   true,
+				Message: "Error importing feeds",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		ctx = &Context{
+			Error:   false,
+			Message: fmt.Sprintf("Successfully imported %d feeds", imported),
+		}
+		s.render("error", w, ctx)
+		return
+	}
+}
+
+// UnfollowHandler ...
+func (s *Server) UnfollowHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		nick := strings.TrimSpace(r.FormValue("nick"))
+
+		if nick == "" {
+			ctx := &Context{
+				Error:   true,
+				Message: "No nick specified to unfollow",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		user := ctx.User
+		if user == nil {
+			log.Fatalf("user not found in context")
+		}
+
+		url, ok := user.Following[nick]
+		if !ok {
+			ctx := &Context{
+				Error:   true,
+				Message: fmt.Sprintf("No feed found by the nick %s", nick),
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		delete(user.Following, nick)
+
+		if err := s.db.SetUser(ctx.Username, user); err != nil {
+			ctx := &Context{
+				Error:   true,
+				Message: fmt.Sprintf("Error unfollowing feed %s: %s", nick, url),
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		ctx = &Context{
+			Error:   false,
+			Message: fmt.Sprintf("Successfully stopped following %s: %s", nick, url),
+		}
+		s.render("error", w, ctx)
+		return
+	}
+}
+
+// SettingsHandler ...
+func (s *Server) SettingsHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		user := ctx.User
+		if user == nil {
+			log.Fatalf("user not found in context")
+		}
+
+		sess, ok := r.Context().Value("sesssion").(*session.Session)
+		if !ok {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		if r.Method == "GET" {
+			sessions, err := s.sm.Find("username", user.Username)
+			if err != nil {
+				log.WithError(err).Errorf("error listing sessions for %s", user.Username)
+			}
+			sort.Slice(sessions, func(i, j int) bool {
+				return sessions[i].LastSeenAt().After(sessions[j].LastSeenAt())
+			})
+
+			ctx.Sessions = sessions
+			ctx.SessionHash = sess.Hash()
+
+			if s.config.OIDCEnabled() {
+				ctx.Identities, err = GetUserIdentities(s.db, user.Username)
+				if err != nil {
+					log.WithError(err).Errorf("error listing identities for %s", user.Username)
+				}
+			}
+
+			s.render("settings", w, ctx)
+			return
+		}
+
+		password := r.FormValue("password")
+
+		if password != "" {
+			if err := ValidatePassword(user.Username, password); err != nil {
+				ctx := &Context{
+					Error:   true,
+					Message: fmt.Sprintf("Error updating password: %s", err),
+				}
+				s.render("error", w, ctx)
+				return
+			}
+
+			hash, err := s.pm.NewPassword(password)
+			if err != nil {
+				log.WithError(err).Error("error creating password hash")
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			user.Password = hash
+		}
+
+		if err := s.db.SetUser(ctx.Username, user); err != nil {
+			ctx := &Context{
+				Error:   true,
+				Message: "Error updating user",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		// Log out everywhere else after a password change
+		if password != "" {
+			if err := s.sm.Purge("username", user.Username, sess); err != nil {
+				log.WithError(err).Errorf("error purging sessions for %s", user.Username)
+			}
+		}
+
+		ctx = &Context{
+			Error:   false,
+			Message: "Successfully updated settings",
+		}
+		s.render("error", w, ctx)
+		return
+	}
+}
+
+// ProfileSettingsHandler ...
+func (s *Server) ProfileSettingsHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		user := ctx.User
+		if user == nil {
+			log.Fatalf("user not found in context")
+		}
+
+		displayName := strings.TrimSpace(r.FormValue("display_name"))
+		description := strings.TrimSpace(r.FormValue("description"))
+		homepage := strings.TrimSpace(r.FormValue("homepage"))
+
+		for _, err := range []error{
+			ValidateDisplayName(displayName),
+			ValidateDescription(description),
+			ValidateHomepage(homepage),
+		} {
+			if err != nil {
+				ctx := &Context{
+					Error:   true,
+					Message: fmt.Sprintf("Error updating profile: %s", err),
+				}
+				s.render("error", w, ctx)
+				return
+			}
+		}
+
+		user.DisplayName = displayName
+		user.Description = description
+		user.Homepage = homepage
+
+		file, _, err := r.FormFile("avatar")
+		if err == nil {
+			defer file.Close()
+
+			hash, err := SaveAvatar(s.config.Data, user.Username, file)
+			if err != nil {
+				log.WithError(err).Warnf("error saving avatar for %s", user.Username)
+				ctx := &Context{
+					Error:   true,
+					Message: fmt.Sprintf("Error updating profile: %s", ErrInvalidAvatar),
+				}
+				s.render("error", w, ctx)
+				return
+			}
+			user.Avatar = hash
+		} else if err != http.ErrMissingFile {
+			log.WithError(err).Warn("error reading avatar upload")
+		} else if r.FormValue("remove_avatar") != "" {
+			if err := DeleteAvatar(s.config.Data, user.Username); err != nil {
+				log.WithError(err).Errorf("error deleting avatar for %s", user.Username)
+			}
+			user.Avatar = ""
+		}
+
+		if err := s.db.SetUser(user.Username, user); err != nil {
+			log.WithError(err).Errorf("error updating user %s", user.Username)
+			ctx := &Context{
+				Error:   true,
+				Message: "Error updating profile",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		if err := WriteFeedMetadata(s.config, user); err != nil {
+			log.WithError(err).Errorf("error writing feed metadata for %s", user.Username)
+		}
+
+		http.Redirect(w, r, URLForProfile("", user.Username), http.StatusFound)
+	}
+}
+
+// RevokeSessionHandler logs out one of the user's other sessions, identified
+// by its hash, or all of them if `all` is given
+func (s *Server) RevokeSessionHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		sess, ok := r.Context().Value("sesssion").(*session.Session)
+		if !ok {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		if r.FormValue("all") != "" {
+			if err := s.sm.Purge("username", ctx.Username, sess); err != nil {
+				log.WithError(err).Errorf("error purging sessions for %s", ctx.Username)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			http.Redirect(w, r, "/settings", http.StatusFound)
+			return
+		}
+
+		sessions, err := s.sm.Find("username", ctx.Username)
+		if err != nil {
+			log.WithError(err).Errorf("error listing sessions for %s", ctx.Username)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		hash := r.FormValue("hash")
+		for _, other := range sessions {
+			if hash != "" && other.Hash() == hash {
+				if err := s.sm.Revoke(other); err != nil {
+					log.WithError(err).Errorf("error revoking session for %s", ctx.Username)
+					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+					return
+				}
+				break
+			}
+		}
+
+		http.Redirect(w, r, "/settings", http.StatusFound)
+	}
+}
+
+// TwoFactorSettingsHandler ...
+func (s *Server) TwoFactorSettingsHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		user := ctx.User
+		if user == nil {
+			log.Fatalf("user not found in context")
+		}
+
+		sess, ok := r.Context().Value("sesssion").(*session.Session)
+		if !ok {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		if user.TOTPSecret != "" {
+			ctx.Reauthenticated = s.reauthenticated(r, user)
+			s.render("2fa_settings", w, ctx)
+			return
+		}
+
+		if r.Method == "GET" {
+			secret, err := totp.GenerateSecret()
+			if err != nil {
+				log.WithError(err).Error("error generating totp secret")
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			sess.Set("2fa_secret", secret)
+
+			ctx.TOTPSecret = secret
+			ctx.TOTPURI = totp.ProvisioningURI(s.config.Name, user.Username, secret)
+			ctx.TOTPQRCode, err = QRCodeDataURI(ctx.TOTPURI)
+			if err != nil {
+				log.WithError(err).Error("error generating qr code")
+			}
+
+			s.render("2fa_settings", w, ctx)
+			return
+		}
+
+		secret, ok := sess.Get("2fa_secret")
+		if !ok || secret == "" {
+			http.Redirect(w, r, "/settings/2fa", http.StatusFound)
+			return
+		}
+
+		step, ok := totp.Validate(secret, r.FormValue("code"), time.Now())
+		if !ok {
+			ctx := &Context{
+				Error:   true,
+				Message: "Invalid authentication code, please go back and try again",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		codes, hashes, err := GenerateRecoveryCodes()
+		if err != nil {
+			log.WithError(err).Error("error generating recovery codes")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		user.TOTPSecret = secret
+		user.TOTPLastStep = step
+		user.RecoveryCodes = hashes
+
+		if err := s.db.SetUser(user.Username, user); err != nil {
+			log.WithError(err).Errorf("error updating user %s", user.Username)
+			ctx := &Context{
+				Error:   true,
+				Message: "Error enabling two-factor authentication",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		sess.Delete("2fa_secret")
+		log.Infof("two-factor authentication enabled for %s", user.Username)
+
+		ctx.RecoveryCodes = codes
+		s.render("2fa_settings", w, ctx)
+	}
+}
+
+// DisableTwoFactorHandler ...
+func (s *Server) DisableTwoFactorHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		user := ctx.User
+		if user == nil {
+			log.Fatalf("user not found in context")
+		}
+
+		if !s.confirmUser(r, user) {
+			SecurityEvent(r, "2fa-disable-failed", user.Username)
+			message := "Incorrect password, two-factor authentication remains enabled"
+			if user.Password == "" {
+				message = "Unable to confirm it is you, two-factor authentication remains enabled"
+			}
+			ctx := &Context{
+				Error:   true,
+				Message: message,
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		user.TOTPSecret = ""
+		user.TOTPLastStep = 0
+		user.RecoveryCodes = nil
+
+		if err := s.db.SetUser(user.Username, user); err != nil {
+			log.WithError(err).Errorf("error updating user %s", user.Username)
+			ctx := &Context{
+				Error:   true,
+				Message: "Error disabling two-factor authentication",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		SecurityEvent(r, "2fa-disabled", user.Username)
+
+		ctx = &Context{
+			Error:   false,
+			Message: "Two-factor authentication has been disabled",
+		}
+		s.render("error", w, ctx)
+	}
+}
+
+// DeleteAccountHandler ...
+func (s *Server) DeleteAccountHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		user := ctx.User
+		if user == nil {
+			log.Fatalf("user not found in context")
+		}
+
+		if r.Method == "GET" {
+			ctx.Reauthenticated = s.reauthenticated(r, user)
+			s.render("delete", w, ctx)
+			return
+		}
+
+		if !s.confirmUser(r, user) {
+			log.Warnf("unable to confirm user deleting account %s", user.Username)
+			message := "Incorrect password, your account has not been deleted"
+			if user.Password == "" {
+				message = "Unable to confirm it is you, your account has not been deleted"
+			}
+			ctx := &Context{
+				Error:   true,
+				Message: message,
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		if err := s.deleteUser(user); err != nil {
+			log.WithError(err).Errorf("error deleting user %s", user.Username)
+			ctx := &Context{
+				Error:   true,
+				Message: "Error deleting your account",
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		s.sm.Delete(w, r)
+
+		log.Infof("user deleted their account: %s", user.Username)
+
+		ctx = &Context{
+			Error:   false,
+			Message: "Your account and feed have been deleted. Sorry to see you go!",
+		}
+		s.render("error", w, ctx)
+	}
+}
+
+// ExportHandler ...
+func (s *Server) ExportHandler() httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		ctx := NewContext(s.config, s.db, r)
 
-		if r.Method == "GET" {
-			s.render("login", w, ctx)
-			return
+		user := ctx.User
+		if user == nil {
+			log.Fatalf("user not found in context")
 		}
 
-		username := r.FormValue("username")
-		password := r.FormValue("password")
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set(
+			"Content-Disposition",
+			fmt.Sprintf("attachment; filename=\"%s.zip\"", user.Username),
+		)
 
-		// Error: no username or password provided
-		if username == "" || password == "" {
-			log.Warn("no username or password provided")
-			http.Redirect(w, r, "/login", http.StatusFound)
+		if err := ExportUser(s.config, user, w); err != nil {
+			log.WithError(err).Errorf("error exporting data for %s", user.Username)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
+	}
+}
 
-		// Lookup user
-		user, err := s.db.GetUser(username)
-		if err != nil {
-			log.WithError(err).Errorf("error looking up user %s", username)
-			http.Redirect(w, r, "/login", http.StatusFound)
+// InvitesHandler ...
+func (s *Server) InvitesHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		if !s.config.InviteOnly {
+			ctx := &Context{
+				Error:   true,
+				Message: "Invites are not enabled on this instance",
+			}
+			s.render("error", w, ctx)
 			return
 		}
 
-		// Validate cleartext password against KDF hash
-		err = s.pm.Check(user.Password, password)
+		var (
+			invites []*Invite
+			err     error
+		)
+
+		if ctx.IsAdmin {
+			invites, err = s.db.GetAllInvites()
+		} else {
+			invites, err = GetUserInvites(s.db, ctx.Username)
+		}
 		if err != nil {
-			log.WithError(err).Errorf("password mismatch for %s", username)
-			http.Redirect(w, r, "/login", http.StatusFound)
+			log.WithError(err).Error("error loading invites")
+			ctx := &Context{
+				Error:   true,
+				Message: "Error loading invites",
+			}
+			s.render("error", w, ctx)
 			return
 		}
 
-		// Login successful
-		log.Infof("login successful: %s", username)
-
-		// Lookup session
-		sess := r.Context().Value("sesssion")
-		if sess == nil {
-			log.Warn("no session found")
-			http.Redirect(w, r, "/login", http.StatusFound)
+		if r.Method == "GET" {
+			sort.Slice(invites, func(i, j int) bool {
+				return invites[i].CreatedAt.After(invites[j].CreatedAt)
+			})
+			ctx.Invites = invites
+			s.render("invites", w, ctx)
 			return
 		}
 
-		// Authorize session
-		sess.(*session.Session).Set("username", username)
-
-		http.Redirect(w, r, "/", http.StatusFound)
-	}
-}
-
-// LogoutHandler ...
-func (s *Server) LogoutHandler() httprouter.Handle {
-	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
-		s.sm.Delete(w, r)
-		http.Redirect(w, r, "/", http.StatusFound)
-	}
-}
-
-// RegisterHandler ...
-func (s *Server) RegisterHandler() httprouter.Handle {
-	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
-		ctx := NewContext(s.config, s.db, r)
-
-		if r.Method == "GET" {
-			if s.config.Register {
-				s.render("register", w, ctx)
-			} else {
-				message := s.config.RegisterMessage
+		maxUses, ttl := 1, DefaultInviteTTL
 
-				if message == "" {
-					message = "Registrations are disabled on this instance. Please contact the operator."
+		if ctx.IsAdmin {
+			if n, err := strconv.Atoi(r.FormValue("uses")); err == nil && n > 0 {
+				maxUses = n
+			}
+			if n, err := strconv.Atoi(r.FormValue("days")); err == nil && n > 0 {
+				ttl = time.Duration(n) * 24 * time.Hour
+				if ttl > MaxInviteTTL {
+					ttl = MaxInviteTTL
 				}
-
+			}
+		} else {
+			outstanding := 0
+			for _, invite := range invites {
+				if invite.Valid() {
+					outstanding++
+				}
+			}
+			if outstanding >= MaxUserInvites {
 				ctx := &Context{
 					Error:   true,
-					Message: message,
+					Message: fmt.Sprintf("You may have at most %d outstanding invites", MaxUserInvites),
 				}
 				s.render("error", w, ctx)
+				return
 			}
-
-			return
 		}
 
-		username := r.FormValue("username")
-		password := r.FormValue("password")
-		email := r.FormValue("email")
-
-		hash, err := s.pm.NewPassword(password)
+		invite, err := CreateInvite(s.db, ctx.Username, maxUses, ttl)
 		if err != nil {
-			log.WithError(err).Error("error creating password hash")
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			log.WithError(err).Error("error creating invite")
+			ctx := &Context{
+				Error:   true,
+				Message: "Error creating invite",
+			}
+			s.render("error", w, ctx)
 			return
 		}
 
-		user := &User{
-			Username:  username,
-			Email:     email,
-			Password:  hash,
-			CreatedAt: time.Now(),
-		}
-
-		s.db.SetUser(username, user)
-
-		log.Infof("user registered: %v", user)
-		http.Redirect(w, r, "/login", http.StatusFound)
+		log.Infof("invite %s created by %s", invite.Code, ctx.Username)
+		http.Redirect(w, r, "/invites", http.StatusFound)
 	}
 }
 
-// FollowHandler ...
-func (s *Server) FollowHandler() httprouter.Handle {
+// RevokeInviteHandler ...
+func (s *Server) RevokeInviteHandler() httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		ctx := NewContext(s.config, s.db, r)
 
-		if r.Method == "GET" {
-			s.render("follow", w, ctx)
-			return
-		}
-
-		nick := strings.TrimSpace(r.FormValue("nick"))
-		url := strings.TrimSpace(r.FormValue("url"))
+		code := strings.TrimSpace(r.FormValue("code"))
 
-		if nick == "" || url == "" {
+		invite, err := s.db.GetInvite(code)
+		if err != nil || (invite.CreatedBy != ctx.Username && !ctx.IsAdmin) {
 			ctx := &Context{
 				Error:   true,
-				Message: "Both nick and url must be specified",
+				Message: "No such invite",
 			}
 			s.render("error", w, ctx)
 			return
 		}
 
-		user := ctx.User
-		if user == nil {
-			log.Fatalf("user not found in context")
-		}
-
-		user.Following[nick] = url
-
-		if err := s.db.SetUser(ctx.Username, user); err != nil {
+		if err := s.db.DelInvite(code); err != nil {
+			log.WithError(err).Errorf("error deleting invite %s", code)
 			ctx := &Context{
 				Error:   true,
-				Message: fmt.Sprintf("Error following feed %s: %s", nick, url),
+				Message: "Error revoking invite",
 			}
 			s.render("error", w, ctx)
 			return
 		}
 
-		ctx = &Context{
-			Error:   false,
-			Message: fmt.Sprintf("Successfully started following %s: %s", nick, url),
+		if ctx.IsAdmin && invite.CreatedBy != ctx.Username {
+			AuditLog(s.config.Data, ctx.Username, "revoke-invite", code)
 		}
-		s.render("error", w, ctx)
-		return
+
+		http.Redirect(w, r, "/invites", http.StatusFound)
 	}
 }
 
-// ImportHandler ...
-func (s *Server) ImportHandler() httprouter.Handle {
+// AdminHandler ...
+func (s *Server) AdminHandler() httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		ctx := NewContext(s.config, s.db, r)
 
-		if r.Method == "GET" {
-			s.render("import", w, ctx)
-			return
-		}
-
-		feeds := r.FormValue("feeds")
-
-		if feeds == "" {
+		users, err := s.db.GetAllUsers()
+		if err != nil {
+			log.WithError(err).Error("error loading users")
 			ctx := &Context{
 				Error:   true,
-				Message: "Nothing to import!",
+				Message: "Error loading users",
 			}
 			s.render("error", w, ctx)
 			return
 		}
 
-		user := ctx.User
-		if user == nil {
-			log.Fatalf("user not found in context")
+		ctx.Query = strings.TrimSpace(r.FormValue("q"))
+		query := strings.ToLower(ctx.Query)
+
+		for _, user := range users {
+			if query == "" ||
+				strings.Contains(strings.ToLower(user.Username), query) ||
+				strings.Contains(strings.ToLower(user.Email), query) {
+				ctx.Users = append(ctx.Users, user)
+			}
 		}
 
-		re := regexp.MustCompile(`(?P<nick>.*?)[: ](?P<url>.*)`)
+		sort.Slice(ctx.Users, func(i, j int) bool {
+			return ctx.Users[i].Username < ctx.Users[j].Username
+		})
 
-		imported := 0
+		s.render("admin", w, ctx)
+	}
+}
 
-		scanner := bufio.NewScanner(strings.NewReader(feeds))
-		for scanner.Scan() {
-			line := scanner.Text()
-			matches := re.FindStringSubmatch(line)
-			if len(matches) == 3 {
-				nick := strings.TrimSpace(matches[1])
-				url := NormalizeURL(strings.TrimSpace(matches[2]))
-				if nick != "" && url != "" {
-					user.Following[nick] = url
-					imported++
-				}
+// AdminDisableHandler ...
+func (s *Server) AdminDisableHandler(disable bool) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+
+		username := strings.TrimSpace(r.FormValue("username"))
+		if username == ctx.Username {
+			ctx := &Context{
+				Error:   true,
+				Message: "You cannot disable your own account",
 			}
+			s.render("error", w, ctx)
+			return
 		}
-		if err := scanner.Err(); err != nil {
-			log.WithError(err).Error("error scanning feeds for import")
+
+		user, err := s.db.GetUser(username)
+		if err != nil {
 			ctx := &Context{
 				Error:   true,
-				Message: "Error importing feeds",
+				Message: fmt.Sprintf("No user found by the username %s", username),
 			}
 			s.render("error", w, ctx)
+			return
 		}
 
-		if err := s.db.SetUser(ctx.Username, user); err != nil {
+		user.Disabled = disable
+
+		if err := s.db.SetUser(username, user); err != nil {
+			log.WithError(err).Errorf("error updating user %s", username)
 			ctx := &Context{
 				Error:   true,
-				Message: "Error importing feeds",
+				Message: fmt.Sprintf("Error updating user %s", username),
 			}
 			s.render("error", w, ctx)
 			return
 		}
 
-		ctx = &Context{
-			Error:   false,
-			Message: fmt.Sprintf("Successfully imported %d feeds", imported),
+		action := "enable-user"
+		if disable {
+			action = "disable-user"
+			if err := s.sm.Purge("username", username); err != nil {
+				log.WithError(err).Errorf("error purging sessions for %s", username)
+			}
 		}
-		s.render("error", w, ctx)
-		return
+		AuditLog(s.config.Data, ctx.Username, action, username)
+
+		http.Redirect(w, r, "/admin", http.StatusFound)
 	}
 }
 
-// UnfollowHandler ...
-func (s *Server) UnfollowHandler() httprouter.Handle {
+// AdminDeleteHandler ...
+func (s *Server) AdminDeleteHandler() httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		ctx := NewContext(s.config, s.db, r)
 
-		nick := strings.TrimSpace(r.FormValue("nick"))
-
-		if nick == "" {
+		username := strings.TrimSpace(r.FormValue("username"))
+		if username == ctx.Username {
 			ctx := &Context{
 				Error:   true,
-				Message: "No nick specified to unfollow",
+				Message: "You cannot delete your own account from here",
 			}
 			s.render("error", w, ctx)
 			return
 		}
 
-		user := ctx.User
-		if user == nil {
-			log.Fatalf("user not found in context")
-		}
-
-		url, ok := user.Following[nick]
-		if !ok {
+		user, err := s.db.GetUser(username)
+		if err != nil {
 			ctx := &Context{
 				Error:   true,
-				Message: fmt.Sprintf("No feed found by the nick %s", nick),
+				Message: fmt.Sprintf("No user found by the username %s", username),
 			}
 			s.render("error", w, ctx)
 			return
 		}
 
-		delete(user.Following, nick)
-
-		if err := s.db.SetUser(ctx.Username, user); err != nil {
+		if err := s.deleteUser(user); err != nil {
+			log.WithError(err).Errorf("error deleting user %s", username)
 			ctx := &Context{
 				Error:   true,
-				Message: fmt.Sprintf("Error unfollowing feed %s: %s", nick, url),
+				Message: fmt.Sprintf("Error deleting user %s", username),
 			}
 			s.render("error", w, ctx)
 			return
 		}
 
-		ctx = &Context{
-			Error:   false,
-			Message: fmt.Sprintf("Successfully stopped following %s: %s", nick, url),
-		}
-		s.render("error", w, ctx)
-		return
+		AuditLog(s.config.Data, ctx.Username, "delete-user", username)
+
+		http.Redirect(w, r, "/admin", http.StatusFound)
 	}
 }
 
-// SettingsHandler ...
-func (s *Server) SettingsHandler() httprouter.Handle {
+// AdminResetHandler ...
+func (s *Server) AdminResetHandler() httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		ctx := NewContext(s.config, s.db, r)
 
-		if r.Method == "GET" {
-			s.render("settings", w, ctx)
+		username := strings.TrimSpace(r.FormValue("username"))
+		password := r.FormValue("password")
+
+		if password == "" {
+			ctx := &Context{
+				Error:   true,
+				Message: "No new password provided",
+			}
+			s.render("error", w, ctx)
 			return
 		}
 
-		password := r.FormValue("password")
-
-		user := ctx.User
-		if user == nil {
-			log.Fatalf("user not found in context")
+		user, err := s.db.GetUser(username)
+		if err != nil {
+			ctx := &Context{
+				Error:   true,
+				Message: fmt.Sprintf("No user found by the username %s", username),
+			}
+			s.render("error", w, ctx)
+			return
 		}
 
-		if password != "" {
-			hash, err := s.pm.NewPassword(password)
-			if err != nil {
-				log.WithError(err).Error("error creating password hash")
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
+		if err := ValidatePassword(username, password); err != nil {
+			ctx := &Context{
+				Error:   true,
+				Message: fmt.Sprintf("Error resetting password: %s", err),
 			}
+			s.render("error", w, ctx)
+			return
+		}
 
-			user.Password = hash
+		hash, err := s.pm.NewPassword(password)
+		if err != nil {
+			log.WithError(err).Error("error creating password hash")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
 
-		if err := s.db.SetUser(ctx.Username, user); err != nil {
+		user.Password = hash
+
+		if err := s.db.SetUser(username, user); err != nil {
+			log.WithError(err).Errorf("error updating user %s", username)
 			ctx := &Context{
 				Error:   true,
-				Message: "Error updating user",
+				Message: fmt.Sprintf("Error updating user %s", username),
 			}
 			s.render("error", w, ctx)
 			return
 		}
 
+		if err := s.sm.Purge("username", username); err != nil {
+			log.WithError(err).Errorf("error purging sessions for %s", username)
+		}
+
+		AuditLog(s.config.Data, ctx.Username, "reset-password", username)
+
 		ctx = &Context{
 			Error:   false,
-			Message: "Successfully updated settings",
+			Message: fmt.Sprintf("Successfully reset password for %s", username),
 		}
 		s.render("error", w, ctx)
-		return
 	}
 }
@@ -0,0 +1,90 @@
+package twtxt
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultInviteTTL is how long an invite code is valid for by default
+	DefaultInviteTTL = 7 * 24 * time.Hour
+
+	// MaxInviteTTL is the longest an invite code can be valid for
+	MaxInviteTTL = 365 * 24 * time.Hour
+
+	// MaxUserInvites is the number of outstanding invites a user who is not
+	// an administrator may have at any one time
+	MaxUserInvites = 5
+)
+
+var (
+	ErrInvalidInvite = errors.New("invite code is invalid, used up or expired")
+)
+
+// invitesMu serializes redemption of invites so that concurrent
+// registrations cannot use an invite more times than permitted
+var invitesMu sync.Mutex
+
+// CreateInvite creates and persists a new invite created by the given user
+func CreateInvite(db Store, createdBy string, maxUses int, ttl time.Duration) (*Invite, error) {
+	code, err := GenerateRandomToken(9)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	invite := &Invite{
+		Code:      code,
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		MaxUses:   maxUses,
+	}
+
+	if err := db.SetInvite(code, invite); err != nil {
+		return nil, err
+	}
+
+	return invite, nil
+}
+
+// RedeemInvite records a use of the invite by username if it is still valid
+func RedeemInvite(db Store, code, username string) (*Invite, error) {
+	invitesMu.Lock()
+	defer invitesMu.Unlock()
+
+	invite, err := db.GetInvite(code)
+	if err != nil {
+		return nil, ErrInvalidInvite
+	}
+
+	if !invite.Valid() {
+		return nil, ErrInvalidInvite
+	}
+
+	invite.UsedBy = append(invite.UsedBy, username)
+
+	if err := db.SetInvite(code, invite); err != nil {
+		return nil, err
+	}
+
+	return invite, nil
+}
+
+// GetUserInvites returns all invites created by the given user
+func GetUserInvites(db Store, username string) ([]*Invite, error) {
+	invites, err := db.GetAllInvites()
+	if err != nil {
+		return nil, err
+	}
+
+	var userInvites []*Invite
+	for _, invite := range invites {
+		if invite.CreatedBy == username {
+			userInvites = append(userInvites, invite)
+		}
+	}
+
+	return userInvites, nil
+}
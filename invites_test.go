@@ -0,0 +1,55 @@
+package twtxt
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRedeemInvite(t *testing.T) {
+	db, _ := newTestStore(t)
+
+	invite, err := CreateInvite(db, "admin", 2, DefaultInviteTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, username := range []string{"alice", "bob"} {
+		if _, err := RedeemInvite(db, invite.Code, username); err != nil {
+			t.Fatalf("redeeming invite for %s: %s", username, err)
+		}
+	}
+
+	// Used up invites are rejected
+	if _, err := RedeemInvite(db, invite.Code, "carol"); err != ErrInvalidInvite {
+		t.Errorf("expected ErrInvalidInvite for a used up invite got %v", err)
+	}
+
+	redeemed, err := db.GetInvite(invite.Code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"alice", "bob"}; !reflect.DeepEqual(redeemed.UsedBy, expected) {
+		t.Errorf("expected invite used by %v got %v", expected, redeemed.UsedBy)
+	}
+
+	// Expired and unknown invites are rejected
+	expired, err := CreateInvite(db, "admin", 1, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RedeemInvite(db, expired.Code, "carol"); err != ErrInvalidInvite {
+		t.Errorf("expected ErrInvalidInvite for an expired invite got %v", err)
+	}
+	if _, err := RedeemInvite(db, "nope", "carol"); err != ErrInvalidInvite {
+		t.Errorf("expected ErrInvalidInvite for an unknown invite got %v", err)
+	}
+
+	invites, err := GetUserInvites(db, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invites) != 2 {
+		t.Errorf("expected 2 invites created by admin got %d", len(invites))
+	}
+}
@@ -1,6 +1,8 @@
 package twtxt
 
 import (
+	"time"
+
 	"github.com/robfig/cron"
 	log "github.com/sirupsen/logrus"
 )
@@ -10,6 +12,7 @@ var Jobs map[string]JobFactory
 func init() {
 	Jobs = map[string]JobFactory{
 		"@every 5m": NewUpdateFeedsJob,
+		"@every 1m": NewPublishDraftsJob,
 	}
 }
 
@@ -37,7 +40,23 @@ func (job *UpdateFeedsJob) Run() {
 
 	for _, user := range users {
 		for u, n := range user.sources {
-			sources[n] = u
+			sources[u] = n
+		}
+	}
+
+	// Also fetch feeds registered through the registry API so that their
+	// tweets, mentions and tags are served by it too
+	feeds, err := job.db.GetAllKnownFeeds()
+	if err != nil {
+		log.WithError(err).Warn("unable to get known feeds from database")
+	}
+	for _, feed := range feeds {
+		if !feed.Registered || feed.Local {
+			continue
+		}
+		url := NormalizeURL(feed.URL)
+		if _, ok := sources[url]; !ok {
+			sources[url] = feed.Nick
 		}
 	}
 
@@ -56,4 +75,36 @@ func (job *UpdateFeedsJob) Run() {
 	} else {
 		log.Info("updated feed cache")
 	}
+
+	if err := UpdateRegistry(job.conf, job.db, cache); err != nil {
+		log.WithError(err).Warn("error updating registry")
+	}
+
+	n, err := DeliverMentions(job.conf, job.db, cache.GetAll(), time.Now())
+	if err != nil {
+		log.WithError(err).Warn("error delivering mentions")
+	} else if n > 0 {
+		log.Infof("delivered %d mentions", n)
+	}
+}
+
+type PublishDraftsJob struct {
+	conf *Config
+	db   Store
+}
+
+func NewPublishDraftsJob(conf *Config, db Store) cron.Job {
+	return &PublishDraftsJob{conf: conf, db: db}
+}
+
+func (job *PublishDraftsJob) Run() {
+	n, err := PublishDueDrafts(job.conf, job.db, time.Now())
+	if err != nil {
+		log.WithError(err).Warn("error publishing scheduled drafts")
+		return
+	}
+
+	if n > 0 {
+		log.Infof("published %d scheduled drafts", n)
+	}
 }
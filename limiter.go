@@ -0,0 +1,159 @@
+package twtxt
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	limiterBaseDelay  = 1 * time.Second
+	limiterMaxDelay   = 30 * time.Second
+	limiterPruneEvery = 1 * time.Minute
+)
+
+type attempts struct {
+	count       int
+	first       time.Time
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// Limiter tracks attempts per key (such as a remote address or username)
+// and enforces a progressively longer delay between consecutive attempts
+// followed by a temporary lockout once too many attempts have been made
+// within the window.
+type Limiter struct {
+	sync.Mutex
+
+	max     int
+	window  time.Duration
+	lockout time.Duration
+
+	// steady limiters count attempts in fixed windows without delaying
+	// consecutive ones
+	steady bool
+
+	attempts map[string]*attempts
+	pruned   time.Time
+
+	now func() time.Time
+}
+
+// NewLimiter returns a Limiter that locks a key out for lockout once max
+// attempts have been made within window
+func NewLimiter(max int, window, lockout time.Duration) *Limiter {
+	return &Limiter{
+		max:      max,
+		window:   window,
+		lockout:  lockout,
+		attempts: make(map[string]*attempts),
+		now:      time.Now,
+	}
+}
+
+// NewRateLimiter returns a Limiter that allows max attempts per window
+// without delaying consecutive ones, e.g: for other servers that make several
+// requests in a row. Keys are locked out for the rest of the window once
+// they reach max.
+func NewRateLimiter(max int, window time.Duration) *Limiter {
+	l := NewLimiter(max, window, 0)
+	l.steady = true
+	return l
+}
+
+func (l *Limiter) delay(count int) time.Duration {
+	if l.steady || count < 2 {
+		return 0
+	}
+
+	delay := limiterBaseDelay << uint(count-2)
+	if delay > limiterMaxDelay || delay <= 0 {
+		delay = limiterMaxDelay
+	}
+	return delay
+}
+
+// Wait returns how long the caller must wait before another attempt for key
+// is permitted, zero if one is permitted now
+func (l *Limiter) Wait(key string) time.Duration {
+	l.Lock()
+	defer l.Unlock()
+
+	now := l.now()
+
+	a, ok := l.attempts[key]
+	if !ok {
+		return 0
+	}
+
+	if now.Before(a.lockedUntil) {
+		return a.lockedUntil.Sub(now)
+	}
+
+	if next := a.last.Add(l.delay(a.count)); now.Before(next) {
+		return next.Sub(now)
+	}
+
+	return 0
+}
+
+// Hit records an attempt for key and returns true if key is now locked out
+func (l *Limiter) Hit(key string) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	a, ok := l.attempts[key]
+	if !ok || now.Sub(a.last) > l.window || (l.steady && now.Sub(a.first) >= l.window) {
+		a = &attempts{first: now}
+		l.attempts[key] = a
+	}
+
+	a.count++
+	a.last = now
+
+	if a.count >= l.max {
+		a.count = 0
+		a.lockedUntil = now.Add(l.lockout)
+		if l.steady {
+			a.lockedUntil = a.first.Add(l.window)
+		}
+		return true
+	}
+
+	return false
+}
+
+// Reset forgets all attempts for key, for example after a successful login
+func (l *Limiter) Reset(key string) {
+	l.Lock()
+	defer l.Unlock()
+
+	delete(l.attempts, key)
+}
+
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.pruned) < limiterPruneEvery {
+		return
+	}
+	l.pruned = now
+
+	for key, a := range l.attempts {
+		if now.Sub(a.last) > l.window && now.After(a.lockedUntil) {
+			delete(l.attempts, key)
+		}
+	}
+}
+
+// RemoteIP returns the address of the client that made the request
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,110 @@
+package twtxt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterProgressiveDelay(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter(5, time.Hour, time.Hour)
+	l.now = func() time.Time { return now }
+
+	if wait := l.Wait("bob"); wait != 0 {
+		t.Fatalf("expected no wait before any attempts, got %s", wait)
+	}
+
+	l.Hit("bob")
+	if wait := l.Wait("bob"); wait != 0 {
+		t.Fatalf("expected no wait after one attempt, got %s", wait)
+	}
+
+	l.Hit("bob")
+	if wait := l.Wait("bob"); wait != limiterBaseDelay {
+		t.Fatalf("expected %s wait after two attempts, got %s", limiterBaseDelay, wait)
+	}
+
+	now = now.Add(limiterBaseDelay)
+	l.Hit("bob")
+	if wait := l.Wait("bob"); wait != 2*limiterBaseDelay {
+		t.Fatalf("expected %s wait after three attempts, got %s", 2*limiterBaseDelay, wait)
+	}
+
+	if wait := l.Wait("alice"); wait != 0 {
+		t.Fatalf("expected other keys to be unaffected, got %s", wait)
+	}
+}
+
+func TestLimiterLockout(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter(3, time.Hour, 15*time.Minute)
+	l.now = func() time.Time { return now }
+
+	if l.Hit("bob") || l.Hit("bob") {
+		t.Fatal("expected no lockout before the maximum number of attempts")
+	}
+	if !l.Hit("bob") {
+		t.Fatal("expected lockout after the maximum number of attempts")
+	}
+
+	if wait := l.Wait("bob"); wait != 15*time.Minute {
+		t.Fatalf("expected to be locked out for 15m, got %s", wait)
+	}
+
+	now = now.Add(15 * time.Minute)
+	if wait := l.Wait("bob"); wait != 0 {
+		t.Fatalf("expected lockout to expire, got %s", wait)
+	}
+}
+
+func TestLimiterWindowAndReset(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter(3, time.Minute, time.Hour)
+	l.now = func() time.Time { return now }
+
+	l.Hit("bob")
+	l.Hit("bob")
+
+	now = now.Add(2 * time.Minute)
+	if l.Hit("bob") {
+		t.Fatal("expected attempts outside the window to be forgotten")
+	}
+
+	l.Hit("bob")
+	l.Reset("bob")
+	if wait := l.Wait("bob"); wait != 0 {
+		t.Fatalf("expected no wait after reset, got %s", wait)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewRateLimiter(3, time.Hour)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if l.Hit("example.org") {
+			t.Fatal("expected no lockout before the maximum number of attempts")
+		}
+		if wait := l.Wait("example.org"); wait != 0 {
+			t.Fatalf("expected no wait between attempts, got %s", wait)
+		}
+		now = now.Add(10 * time.Minute)
+	}
+
+	if !l.Hit("example.org") {
+		t.Fatal("expected lockout after the maximum number of attempts")
+	}
+	if wait := l.Wait("example.org"); wait != 40*time.Minute {
+		t.Fatalf("expected to be locked out for the rest of the window, got %s", wait)
+	}
+
+	// Attempts are counted afresh each window however close together they are
+	now = now.Add(40 * time.Minute)
+	if wait := l.Wait("example.org"); wait != 0 {
+		t.Fatalf("expected lockout to expire with the window, got %s", wait)
+	}
+	if l.Hit("example.org") {
+		t.Fatal("expected a new window after the last one ended")
+	}
+}
@@ -0,0 +1,183 @@
+package twtxt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // register GIF decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"golang.org/x/image/draw"
+)
+
+const (
+	mediaDir = "media"
+
+	// MaxMediaFileSize is the maximum size of an uploaded image in bytes
+	MaxMediaFileSize = 8 << 20
+
+	// MediaThumbnailSize is the maximum width and height of thumbnails
+	MediaThumbnailSize = 320
+
+	// maxMediaDimension and maxMediaPixels bound the size of uploaded images
+	// to refuse decompression bombs before decoding them
+	maxMediaDimension = 8192
+	maxMediaPixels    = 24000000
+
+	mediaJPEGQuality = 90
+)
+
+// ErrInvalidMedia is returned for uploads that are not a supported image
+var ErrInvalidMedia = errors.New("media must be a PNG, JPEG or GIF image of at most 8MB and 24 megapixels")
+
+// mediaContentTypes maps the content types accepted for uploads to the
+// format the image package decodes them as
+var mediaContentTypes = map[string]string{
+	"image/png":  "png",
+	"image/jpeg": "jpeg",
+	"image/gif":  "gif",
+}
+
+var validMediaName = regexp.MustCompile(`^[0-9a-f]{32}(-thumb)?\.(jpg|png)$`)
+
+// URLForMedia returns the URL of a stored image or thumbnail
+func URLForMedia(baseURL, name string) string {
+	return fmt.Sprintf("%s/media/%s", strings.TrimSuffix(baseURL, "/"), name)
+}
+
+// ThumbnailName returns the name of the thumbnail of the given image
+func ThumbnailName(name string) string {
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "-thumb" + ext
+}
+
+// ValidMediaName reports whether name is the name of an image or thumbnail
+// stored by SaveMedia
+func ValidMediaName(name string) bool {
+	return validMediaName.MatchString(name)
+}
+
+func mediaPath(path, name string) (string, error) {
+	return securejoin.SecureJoin(filepath.Join(path, mediaDir), name)
+}
+
+// SaveMedia validates an uploaded image, re-encodes it to strip any metadata
+// it carried such as EXIF and stores it along with a thumbnail. JPEGs are
+// stored as JPEG and everything else as PNG, so GIFs lose their animation.
+// It returns the name of the stored image, derived from its contents.
+func SaveMedia(path string, r io.Reader) (string, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, MaxMediaFileSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > MaxMediaFileSize {
+		return "", ErrInvalidMedia
+	}
+
+	expected, ok := mediaContentTypes[http.DetectContentType(data)]
+	if !ok {
+		return "", ErrInvalidMedia
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || format != expected {
+		return "", ErrInvalidMedia
+	}
+	if config.Width > maxMediaDimension || config.Height > maxMediaDimension ||
+		config.Width*config.Height > maxMediaPixels {
+		return "", ErrInvalidMedia
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", ErrInvalidMedia
+	}
+
+	encode, ext := encodePNG, ".png"
+	if format == "jpeg" {
+		encode, ext = encodeJPEG, ".jpg"
+	}
+
+	var buf bytes.Buffer
+	if err := encode(&buf, src); err != nil {
+		return "", err
+	}
+
+	var thumb bytes.Buffer
+	if err := encode(&thumb, thumbnail(src, MediaThumbnailSize)); err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	name := hex.EncodeToString(sum[:16]) + ext
+
+	if err := os.MkdirAll(filepath.Join(path, mediaDir), 0755); err != nil {
+		return "", err
+	}
+
+	for fn, data := range map[string][]byte{
+		name:                buf.Bytes(),
+		ThumbnailName(name): thumb.Bytes(),
+	} {
+		p, err := mediaPath(path, fn)
+		if err != nil {
+			return "", err
+		}
+		if err := writeFileAtomic(p, data, 0644); err != nil {
+			return "", err
+		}
+	}
+
+	return name, nil
+}
+
+func encodePNG(w io.Writer, m image.Image) error {
+	return png.Encode(w, m)
+}
+
+func encodeJPEG(w io.Writer, m image.Image) error {
+	return jpeg.Encode(w, m, &jpeg.Options{Quality: mediaJPEGQuality})
+}
+
+// thumbnail scales the image down to fit in a size x size square, keeping
+// its aspect ratio. Images that already fit are returned as is.
+func thumbnail(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	if b.Dx() <= size && b.Dy() <= size {
+		return src
+	}
+
+	w, h := size, b.Dy()*size/b.Dx()
+	if b.Dy() > b.Dx() {
+		w, h = b.Dx()*size/b.Dy(), size
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+	return dst
+}
+
+// MediaLink returns the Markdown-style image link referencing the stored
+// image in tweets, which plain twtxt clients still show as a URL
+func MediaLink(baseURL, name, alt string) string {
+	alt = strings.NewReplacer("[", "", "]", "").Replace(CleanTweetText(alt))
+	return fmt.Sprintf("![%s](%s)", alt, URLForMedia(baseURL, name))
+}
@@ -0,0 +1,109 @@
+package twtxt
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// jpegWithEXIF returns a JPEG image carrying an EXIF segment
+func jpegWithEXIF(t *testing.T, w, h int) []byte {
+	src := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			src.Set(x, y, color.RGBA{30, 30, 200, 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	payload := []byte("Exif\x00\x00GPS 51.5N 0.12W")
+	segment := []byte{0xff, 0xe1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}
+	segment = append(segment, payload...)
+
+	return append(append(append([]byte{}, data[:2]...), segment...), data[2:]...)
+}
+
+func TestSaveMedia(t *testing.T) {
+	dir, err := ioutil.TempDir("", "twtxt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name, err := SaveMedia(dir, bytes.NewReader(jpegWithEXIF(t, 1200, 600)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ValidMediaName(name) || !strings.HasSuffix(name, ".jpg") {
+		t.Fatalf("unexpected media name %q", name)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, mediaDir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("Exif")) {
+		t.Error("expected EXIF data to be stripped")
+	}
+
+	f, err := os.Open(filepath.Join(dir, mediaDir, ThumbnailName(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	config, format, err := image.DecodeConfig(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "jpeg" || config.Width != MediaThumbnailSize || config.Height != MediaThumbnailSize/2 {
+		t.Errorf("unexpected %s thumbnail of %dx%d", format, config.Width, config.Height)
+	}
+
+	for _, data := range []string{
+		"not an image",
+		"<svg xmlns=\"http://www.w3.org/2000/svg\"><script>alert(1)</script></svg>",
+		"\xff\xd8\xff\xe0 truncated jpeg",
+	} {
+		if _, err := SaveMedia(dir, strings.NewReader(data)); err != ErrInvalidMedia {
+			t.Errorf("expected ErrInvalidMedia for %q got %v", data, err)
+		}
+	}
+
+	big := make([]byte, MaxMediaFileSize+1)
+	if _, err := SaveMedia(dir, bytes.NewReader(big)); err != ErrInvalidMedia {
+		t.Errorf("expected ErrInvalidMedia for oversized upload got %v", err)
+	}
+}
+
+func TestValidMediaName(t *testing.T) {
+	for name, expected := range map[string]bool{
+		"0123456789abcdef0123456789abcdef.jpg":       true,
+		"0123456789abcdef0123456789abcdef-thumb.png": true,
+		"0123456789abcdef0123456789abcdef.svg":       false,
+		"../0123456789abcdef0123456789abcdef.jpg":    false,
+		"avatar.png": false,
+	} {
+		if actual := ValidMediaName(name); actual != expected {
+			t.Errorf("expected ValidMediaName(%q) to be %t", name, expected)
+		}
+	}
+}
+
+func TestMediaLink(t *testing.T) {
+	actual := MediaLink("https://example.com/", "abcd.png", "a [cat]\nsleeping")
+	if expected := "![a cat sleeping](https://example.com/media/abcd.png)"; actual != expected {
+		t.Errorf("expected %q got %q", expected, actual)
+	}
+}
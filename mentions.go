@@ -0,0 +1,392 @@
+package twtxt
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// MaxUserMentions is the number of mentions kept in each user's inbox,
+	// older ones are dropped
+	MaxUserMentions = 200
+
+	// MaxMentionAge is how old a tweet may be to still be delivered to the
+	// inboxes of the users it mentions, so that following a feed with a
+	// long history does not flood them
+	MaxMentionAge = 7 * 24 * time.Hour
+
+	// MaxMentionSuggestions is the number of feeds returned when looking up
+	// nicks to mention
+	MaxMentionSuggestions = 10
+)
+
+// mentionsMu serializes changes to the inboxes so that their counts of
+// unread mentions stay accurate
+var mentionsMu sync.Mutex
+
+// MentionSuggestion is a feed that can be mentioned, as returned when
+// looking up nicks to mention
+type MentionSuggestion struct {
+	Nick      string `json:"nick"`
+	URL       string `json:"url"`
+	Local     bool   `json:"local"`
+	Following bool   `json:"following"`
+}
+
+// knownFeeds returns the URLs of the feeds in the cache by their nick
+func knownFeeds(cache Cache) map[string][]string {
+	feeds := make(map[string][]string)
+	for url, cached := range cache {
+		if len(cached.Tweets) == 0 {
+			continue
+		}
+		nick := cached.Tweets[0].Tweeter.Nick
+		feeds[nick] = append(feeds[nick], url)
+	}
+	return feeds
+}
+
+// ResolveMentions turns "@nick" into "@<nick URL>" looking nick up in the
+// feeds the user follows, then the local users and then the feeds seen in
+// the cache, as long as only one of them goes by that nick. Nicks that
+// cannot be resolved are left as is.
+func ResolveMentions(conf *Config, db Store, cache Cache, user *User, text string) string {
+	var feeds map[string][]string
+
+	return expandMentions(text, func(nick string) (string, bool) {
+		if url, ok := user.Following[nick]; ok {
+			return url, true
+		}
+
+		if local, err := db.GetUser(nick); err == nil && !local.Disabled {
+			return URLForUser(conf.BaseURL, local.Username), true
+		}
+
+		if feeds == nil {
+			feeds = knownFeeds(cache)
+		}
+		if urls := feeds[nick]; len(urls) == 1 {
+			return urls[0], true
+		}
+
+		return "", false
+	})
+}
+
+// resolveMentions resolves the mentions in the text using the current cache
+func resolveMentions(conf *Config, db Store, user *User, text string) string {
+	cache, err := SharedCache(conf.Data)
+	if err != nil {
+		log.WithError(err).Warn("error loading cache to resolve mentions")
+		cache = make(Cache)
+	}
+	return ResolveMentions(conf, db, cache, user, text)
+}
+
+// LookupMentions returns the feeds the user may mention whose nick starts
+// with prefix, ignoring case. Feeds the user follows come first, then local
+// users and then the feeds seen in the cache.
+func LookupMentions(conf *Config, db Store, cache Cache, user *User, prefix string) ([]MentionSuggestion, error) {
+	prefix = strings.ToLower(prefix)
+	matches := func(nick string) bool {
+		return strings.HasPrefix(strings.ToLower(nick), prefix)
+	}
+
+	var (
+		suggestions []MentionSuggestion
+		seen        = make(map[string]bool)
+	)
+
+	add := func(group []MentionSuggestion) {
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].Nick < group[j].Nick
+		})
+		for _, suggestion := range group {
+			if !seen[suggestion.URL] {
+				seen[suggestion.URL] = true
+				suggestions = append(suggestions, suggestion)
+			}
+		}
+	}
+
+	var following []MentionSuggestion
+	for nick, url := range user.Following {
+		if matches(nick) {
+			following = append(following, MentionSuggestion{
+				Nick:      nick,
+				URL:       url,
+				Local:     strings.HasPrefix(url, URLForUser(conf.BaseURL, "")),
+				Following: true,
+			})
+		}
+	}
+	add(following)
+
+	users, err := db.GetAllUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	var local []MentionSuggestion
+	for _, u := range users {
+		if !u.Disabled && u.Username != user.Username && matches(u.Username) {
+			local = append(local, MentionSuggestion{
+				Nick:  u.Username,
+				URL:   URLForUser(conf.BaseURL, u.Username),
+				Local: true,
+			})
+		}
+	}
+	add(local)
+
+	var remote []MentionSuggestion
+	for nick, urls := range knownFeeds(cache) {
+		if matches(nick) {
+			for _, url := range urls {
+				remote = append(remote, MentionSuggestion{Nick: nick, URL: url})
+			}
+		}
+	}
+	add(remote)
+
+	if len(suggestions) > MaxMentionSuggestions {
+		suggestions = suggestions[:MaxMentionSuggestions]
+	}
+
+	return suggestions, nil
+}
+
+// MentionedUsers returns the usernames of the local users mentioned in the
+// text as `@<nick URL>` with URL being their feed
+func MentionedUsers(conf *Config, text string) []string {
+	prefix := URLForUser(conf.BaseURL, "")
+
+	var usernames []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		if !strings.HasPrefix(match[2], prefix) {
+			continue
+		}
+
+		username := strings.TrimPrefix(match[2], prefix)
+		if ValidateUsername(username) != nil || seen[username] {
+			continue
+		}
+		seen[username] = true
+		usernames = append(usernames, username)
+	}
+
+	return usernames
+}
+
+// DeliverMentions adds the tweets to the inboxes of the local users they
+// mention, skipping tweets that were already delivered or are older than
+// MaxMentionAge as of now. It returns the number of new mentions.
+func DeliverMentions(conf *Config, db Store, tweets Tweets, now time.Time) (int, error) {
+	mentionsMu.Lock()
+	defer mentionsMu.Unlock()
+
+	delivered := 0
+	recipients := make(map[string]int)
+
+	for _, tweet := range tweets {
+		if now.Sub(tweet.Created) > MaxMentionAge {
+			continue
+		}
+
+		for _, username := range MentionedUsers(conf, tweet.Text) {
+			// Don't notify users of their own tweets
+			if tweet.Tweeter.URL == URLForUser(conf.BaseURL, username) {
+				continue
+			}
+
+			id := tweet.Hash()
+			if _, err := db.GetMention(username, id); err == nil {
+				continue
+			} else if err != ErrMentionNotFound {
+				return delivered, err
+			}
+
+			user, err := db.GetUser(username)
+			if err == ErrUserNotFound {
+				continue
+			} else if err != nil {
+				return delivered, err
+			}
+			if user.Disabled {
+				continue
+			}
+
+			mention := &Mention{
+				ID:        id,
+				Username:  username,
+				Tweet:     tweet,
+				CreatedAt: now,
+			}
+			if err := db.SetMention(username, id, mention); err != nil {
+				return delivered, err
+			}
+
+			delivered++
+			recipients[username]++
+		}
+	}
+
+	for username, n := range recipients {
+		pruned, err := pruneMentions(db, username)
+		if err != nil {
+			log.WithError(err).Warnf("error pruning mentions of %s", username)
+		}
+		if err := addUnreadMentions(db, username, n-pruned); err != nil {
+			return delivered, err
+		}
+	}
+
+	return delivered, nil
+}
+
+// RedeliverMentions replaces the mentions of a tweet by the mentions of its
+// edited version, so that the users it mentions see the tweet as edited and
+// only once. It returns the number of new mentions.
+func RedeliverMentions(conf *Config, db Store, old, edited Tweet, now time.Time) (int, error) {
+	if old.Hash() == edited.Hash() {
+		return 0, nil
+	}
+
+	if err := dropMentions(conf, db, old); err != nil {
+		return 0, err
+	}
+	return DeliverMentions(conf, db, Tweets{edited}, now)
+}
+
+// dropMentions deletes the tweet from the inboxes of the users it mentions
+func dropMentions(conf *Config, db Store, tweet Tweet) error {
+	mentionsMu.Lock()
+	defer mentionsMu.Unlock()
+
+	id := tweet.Hash()
+	for _, username := range MentionedUsers(conf, tweet.Text) {
+		mention, err := db.GetMention(username, id)
+		if err == ErrMentionNotFound {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if err := db.DelMention(username, id); err != nil {
+			return err
+		}
+		if !mention.Read {
+			if err := addUnreadMentions(db, username, -1); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// addUnreadMentions adjusts the user's count of unread mentions by n, which
+// must be called with mentionsMu held
+func addUnreadMentions(db Store, username string, n int) error {
+	if n == 0 {
+		return nil
+	}
+
+	unread, err := db.GetUnreadMentions(username)
+	if err != nil {
+		return err
+	}
+	return db.SetUnreadMentions(username, unread+n)
+}
+
+// pruneMentions drops the oldest mentions of the user beyond MaxUserMentions
+// and returns how many of them were unread
+func pruneMentions(db Store, username string) (int, error) {
+	mentions, err := GetUserMentions(db, username)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(mentions) <= MaxUserMentions {
+		return 0, nil
+	}
+
+	unread := 0
+	for _, mention := range mentions[MaxUserMentions:] {
+		if err := db.DelMention(username, mention.ID); err != nil {
+			return unread, err
+		}
+		if !mention.Read {
+			unread++
+		}
+	}
+
+	return unread, nil
+}
+
+// GetUserMentions returns the mentions in the user's inbox, newest first
+func GetUserMentions(db Store, username string) ([]*Mention, error) {
+	mentions, err := db.GetMentions(username)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(mentions, func(i, j int) bool {
+		return mentions[i].Tweet.Created.After(mentions[j].Tweet.Created)
+	})
+
+	return mentions, nil
+}
+
+// UnreadMentions returns the number of unread mentions in the user's inbox,
+// kept as a counter so that it is cheap to show on every page
+func UnreadMentions(db Store, username string) (int, error) {
+	return db.GetUnreadMentions(username)
+}
+
+// MarkMentionsRead marks all mentions in the user's inbox as read
+func MarkMentionsRead(db Store, username string) error {
+	mentionsMu.Lock()
+	defer mentionsMu.Unlock()
+
+	mentions, err := db.GetMentions(username)
+	if err != nil {
+		return err
+	}
+
+	for _, mention := range mentions {
+		if mention.Read {
+			continue
+		}
+		mention.Read = true
+		if err := db.SetMention(username, mention.ID, mention); err != nil {
+			return err
+		}
+	}
+
+	return db.SetUnreadMentions(username, 0)
+}
+
+// DeleteUserMentions empties the user's inbox
+func DeleteUserMentions(db Store, username string) error {
+	mentionsMu.Lock()
+	defer mentionsMu.Unlock()
+
+	mentions, err := db.GetMentions(username)
+	if err != nil {
+		return err
+	}
+
+	for _, mention := range mentions {
+		if err := db.DelMention(username, mention.ID); err != nil {
+			return err
+		}
+	}
+
+	return db.SetUnreadMentions(username, 0)
+}
@@ -0,0 +1,157 @@
+package twtxt
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMentionedUsers(t *testing.T) {
+	conf := &Config{BaseURL: "https://example.com/"}
+
+	text := "hi @<alice https://example.com/u/alice> @<bob https://elsewhere.com/u/bob> " +
+		"@<a https://example.com/u/alice> @<x https://example.com/u/../admin> @<carol https://example.com/u/carol>"
+
+	expected := []string{"alice", "carol"}
+	if actual := MentionedUsers(conf, text); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v got %v", expected, actual)
+	}
+}
+
+func TestDeliverMentions(t *testing.T) {
+	db, dir := newTestStore(t)
+
+	for _, user := range []*User{{Username: "alice"}, {Username: "carol", Disabled: true}} {
+		if err := db.SetUser(user.Username, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conf := &Config{Data: dir, BaseURL: "https://example.com"}
+	now := time.Date(2020, 7, 20, 12, 0, 0, 0, time.UTC)
+
+	bob := Tweeter{Nick: "bob", URL: "https://elsewhere.com/u/bob"}
+	alice := Tweeter{Nick: "alice", URL: URLForUser(conf.BaseURL, "alice")}
+
+	mentionsAlice := "hello @<alice https://example.com/u/alice>"
+	tweets := Tweets{
+		{Tweeter: bob, Text: mentionsAlice, Created: now.Add(-time.Hour)},
+		{Tweeter: bob, Text: mentionsAlice + " again", Created: now.Add(-MaxMentionAge - time.Hour)},
+		{Tweeter: alice, Text: "talking to myself " + mentionsAlice, Created: now},
+		{Tweeter: bob, Text: "hi @<carol https://example.com/u/carol> @<dave https://example.com/u/dave>", Created: now},
+	}
+
+	n, err := DeliverMentions(conf, db, tweets, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 delivered mention got %d", n)
+	}
+
+	// Delivering the same tweets again must not duplicate them
+	if n, err := DeliverMentions(conf, db, tweets, now); err != nil || n != 0 {
+		t.Errorf("expected no new mentions got %d (%v)", n, err)
+	}
+
+	mentions, err := GetUserMentions(db, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mentions) != 1 || mentions[0].Tweet.Text != mentionsAlice || mentions[0].Read {
+		t.Fatalf("unexpected mentions %+v", mentions)
+	}
+
+	if unread, err := UnreadMentions(db, "alice"); err != nil || unread != 1 {
+		t.Errorf("expected 1 unread mention got %d (%v)", unread, err)
+	}
+	if err := MarkMentionsRead(db, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if unread, err := UnreadMentions(db, "alice"); err != nil || unread != 0 {
+		t.Errorf("expected no unread mentions got %d (%v)", unread, err)
+	}
+}
+
+func TestRedeliverMentions(t *testing.T) {
+	db, dir := newTestStore(t)
+
+	for _, username := range []string{"alice", "carol"} {
+		if err := db.SetUser(username, &User{Username: username}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conf := &Config{Data: dir, BaseURL: "https://example.com"}
+	now := time.Date(2020, 7, 20, 12, 0, 0, 0, time.UTC)
+
+	bob := Tweeter{Nick: "bob", URL: "https://elsewhere.com/u/bob"}
+	old := Tweet{Tweeter: bob, Text: "hi @<alice https://example.com/u/alice>", Created: now}
+	edited := old
+	edited.Text = "hi @<alice https://example.com/u/alice> and @<carol https://example.com/u/carol>"
+
+	if _, err := DeliverMentions(conf, db, Tweets{old}, now); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := RedeliverMentions(conf, db, old, edited, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 delivered mentions got %d", n)
+	}
+
+	for _, username := range []string{"alice", "carol"} {
+		mentions, err := GetUserMentions(db, username)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(mentions) != 1 || mentions[0].Tweet.Text != edited.Text {
+			t.Errorf("expected only the edited tweet in the inbox of %s got %+v", username, mentions)
+		}
+		if unread, err := UnreadMentions(db, username); err != nil || unread != 1 {
+			t.Errorf("expected 1 unread mention for %s got %d (%v)", username, unread, err)
+		}
+	}
+}
+
+func TestResolveMentions(t *testing.T) {
+	db, dir := newTestStore(t)
+
+	for _, user := range []*User{{Username: "alice"}, {Username: "carol"}, {Username: "mallory", Disabled: true}} {
+		if err := db.SetUser(user.Username, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conf := &Config{Data: dir, BaseURL: "https://example.com"}
+	user := &User{
+		Username:  "alice",
+		Following: map[string]string{"carol": "https://carol.example.org/twtxt.txt"},
+	}
+	cache := Cache{
+		"https://bob.example.org/twtxt.txt": {Tweets: Tweets{{Tweeter: Tweeter{Nick: "bob", URL: "https://bob.example.org/twtxt.txt"}}}},
+		"https://x.example.org/twtxt.txt":   {Tweets: Tweets{{Tweeter: Tweeter{Nick: "dup", URL: "https://x.example.org/twtxt.txt"}}}},
+		"https://y.example.org/twtxt.txt":   {Tweets: Tweets{{Tweeter: Tweeter{Nick: "dup", URL: "https://y.example.org/twtxt.txt"}}}},
+	}
+
+	actual := ResolveMentions(conf, db, cache, user, "@carol @alice @bob @dup @mallory @nobody")
+	expected := "@<carol https://carol.example.org/twtxt.txt> @<alice https://example.com/u/alice> " +
+		"@<bob https://bob.example.org/twtxt.txt> @dup @mallory @nobody"
+	if actual != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+
+	suggestions, err := LookupMentions(conf, db, cache, user, "C")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedSuggestions := []MentionSuggestion{
+		{Nick: "carol", URL: "https://carol.example.org/twtxt.txt", Following: true},
+		{Nick: "carol", URL: "https://example.com/u/carol", Local: true},
+	}
+	if !reflect.DeepEqual(suggestions, expectedSuggestions) {
+		t.Errorf("expected %+v got %+v", expectedSuggestions, suggestions)
+	}
+}
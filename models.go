@@ -11,6 +11,21 @@ type User struct {
 	Email     string
 	CreatedAt time.Time
 
+	DisplayName string
+	Description string
+	Homepage    string
+	Avatar      string
+
+	Admin    bool
+	Disabled bool
+	Verified bool
+
+	InvitedBy string
+
+	TOTPSecret    string
+	TOTPLastStep  int64
+	RecoveryCodes []string
+
 	Following map[string]string
 
 	url     string
@@ -45,6 +60,12 @@ func (u *User) Sources() map[string]string {
 	return u.sources
 }
 
+// Follows returns whether the user follows the feed at the given URL
+func (u *User) Follows(url string) bool {
+	_, ok := u.sources[NormalizeURL(url)]
+	return ok
+}
+
 func (u *User) Bytes() ([]byte, error) {
 	data, err := json.Marshal(u)
 	if err != nil {
@@ -75,3 +96,170 @@ func (s *Session) Bytes() ([]byte, error) {
 	}
 	return data, nil
 }
+
+// Token is a single use token sent to a user's email address
+type Token struct {
+	Kind      string
+	Username  string
+	Email     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+func LoadToken(data []byte) (token *Token, err error) {
+	if err = json.Unmarshal(data, &token); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (t *Token) Bytes() ([]byte, error) {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Invite is an invite code that permits registering a limited number of
+// accounts when the instance is in invite only mode
+type Invite struct {
+	Code      string
+	CreatedBy string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+
+	MaxUses int
+	UsedBy  []string
+}
+
+func LoadInvite(data []byte) (invite *Invite, err error) {
+	if err = json.Unmarshal(data, &invite); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Valid returns whether the invite is unexpired and has uses left
+func (i *Invite) Valid() bool {
+	return time.Now().Before(i.ExpiresAt) && len(i.UsedBy) < i.MaxUses
+}
+
+func (i *Invite) Bytes() ([]byte, error) {
+	data, err := json.Marshal(i)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Identity links an account at an external OpenID Connect provider to a user
+type Identity struct {
+	Issuer    string
+	Subject   string
+	Username  string
+	CreatedAt time.Time
+}
+
+func LoadIdentity(data []byte) (identity *Identity, err error) {
+	if err = json.Unmarshal(data, &identity); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (i *Identity) Bytes() ([]byte, error) {
+	data, err := json.Marshal(i)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Draft is an unpublished tweet, saved to finish later or scheduled to be
+// published at PublishAt
+type Draft struct {
+	ID        string
+	Username  string
+	Text      string
+	CreatedAt time.Time
+	PublishAt time.Time
+
+	// Error is why a scheduled draft was not published
+	Error string
+}
+
+func LoadDraft(data []byte) (draft *Draft, err error) {
+	if err = json.Unmarshal(data, &draft); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Scheduled returns whether the draft is scheduled to be published
+func (d *Draft) Scheduled() bool {
+	return !d.PublishAt.IsZero()
+}
+
+func (d *Draft) Bytes() ([]byte, error) {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Mention is a tweet mentioning a local user, kept in their mentions inbox
+type Mention struct {
+	ID       string
+	Username string
+	Tweet    Tweet
+	Read     bool
+
+	CreatedAt time.Time
+}
+
+func LoadMention(data []byte) (mention *Mention, err error) {
+	if err = json.Unmarshal(data, &mention); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (m *Mention) Bytes() ([]byte, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// KnownFeed is a feed recorded in the registry, either of a local user,
+// followed by one or registered by someone
+type KnownFeed struct {
+	Nick        string
+	URL         string
+	Description string
+
+	Local      bool
+	Registered bool
+	Followers  int
+
+	LastUpdated time.Time
+	CreatedAt   time.Time
+}
+
+func LoadKnownFeed(data []byte) (feed *KnownFeed, err error) {
+	if err = json.Unmarshal(data, &feed); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (f *KnownFeed) Bytes() ([]byte, error) {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
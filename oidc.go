@@ -0,0 +1,95 @@
+package twtxt
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prologic/twtxt/auth"
+)
+
+// identityKey returns the store key of an identity at an OpenID Connect
+// provider, hashed to keep it short
+func identityKey(issuer, subject string) string {
+	return tokenKey(issuer + " " + subject)
+}
+
+// LinkIdentity links the identity at an OpenID Connect provider to the user
+func LinkIdentity(db Store, identity *auth.Identity, username string) error {
+	return db.SetIdentity(identityKey(identity.Issuer, identity.Subject), &Identity{
+		Issuer:    identity.Issuer,
+		Subject:   identity.Subject,
+		Username:  username,
+		CreatedAt: time.Now(),
+	})
+}
+
+// GetUserIdentities returns the identities linked to the user
+func GetUserIdentities(db Store, username string) ([]*Identity, error) {
+	identities, err := db.GetAllIdentities()
+	if err != nil {
+		return nil, err
+	}
+
+	var userIdentities []*Identity
+	for _, identity := range identities {
+		if strings.EqualFold(identity.Username, username) {
+			userIdentities = append(userIdentities, identity)
+		}
+	}
+
+	return userIdentities, nil
+}
+
+// UnlinkUserIdentities removes all identities linked to the user
+func UnlinkUserIdentities(db Store, username string) error {
+	identities, err := GetUserIdentities(db, username)
+	if err != nil {
+		return err
+	}
+
+	for _, identity := range identities {
+		if err := db.DelIdentity(identityKey(identity.Issuer, identity.Subject)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// provisionUser creates an account for a user logging in with an OpenID
+// Connect provider for the first time, named after their identity's
+// username claim
+func (s *Server) provisionUser(identity *auth.Identity) (*User, error) {
+	username := identity.Username
+	if err := ValidateUsername(username); err != nil {
+		return nil, err
+	}
+
+	exists, err := UsernameExists(s.db, username)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrUsernameTaken
+	}
+
+	user := &User{
+		Username:  username,
+		CreatedAt: time.Now(),
+	}
+
+	if ValidateEmail(identity.Email) == nil {
+		user.Email = identity.Email
+		user.Verified = identity.EmailVerified
+	}
+
+	if err := s.db.SetUser(username, user); err != nil {
+		return nil, err
+	}
+
+	if err := LinkIdentity(s.db, identity, username); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
@@ -1,5 +1,9 @@
 package twtxt
 
+import (
+	"time"
+)
+
 const (
 	// DefaultData is the default data directory for storage
 	DefaultData = "./data"
@@ -15,6 +19,27 @@ const (
 
 	// DefaultRegister is the default user registration flag
 	DefaultRegister = false
+
+	// DefaultSessionExpiry is the default lifetime of a session
+	DefaultSessionExpiry = 24 * time.Hour
+
+	// DefaultSessionRememberExpiry is the default lifetime of a session when
+	// the user asks to be remembered
+	DefaultSessionRememberExpiry = 30 * 24 * time.Hour
+
+	// DefaultPasswordHasher is the default password hashing algorithm and
+	// parameters
+	DefaultPasswordHasher = "argon2id:t=3,m=65536,p=2"
+
+	// DefaultOIDCName is the default name of the OpenID Connect provider
+	// shown on the login page
+	DefaultOIDCName = "Single Sign-On"
+
+	// DefaultMailer is the default mailer used to send emails
+	DefaultMailer = "log://"
+
+	// DefaultMailFrom is the default address emails are sent from
+	DefaultMailFrom = "twtxt <no-reply@twtxt.net>"
 )
 
 func NewConfig() *Config {
@@ -22,6 +47,16 @@ func NewConfig() *Config {
 		Data:    DefaultData,
 		Store:   DefaultStore,
 		BaseURL: DefaultBaseURL,
+
+		Mailer:   DefaultMailer,
+		MailFrom: DefaultMailFrom,
+
+		PasswordHasher: DefaultPasswordHasher,
+
+		OIDCName: DefaultOIDCName,
+
+		SessionExpiry:         DefaultSessionExpiry,
+		SessionRememberExpiry: DefaultSessionRememberExpiry,
 	}
 }
 
@@ -67,3 +102,117 @@ func WithRegister(register bool) Option {
 		return nil
 	}
 }
+
+// WithInviteOnly sets the invite only registration flag
+func WithInviteOnly(inviteOnly bool) Option {
+	return func(cfg *Config) error {
+		cfg.InviteOnly = inviteOnly
+		return nil
+	}
+}
+
+// WithAdminUser sets the username of the bootstrap administrator
+func WithAdminUser(adminUser string) Option {
+	return func(cfg *Config) error {
+		cfg.AdminUser = adminUser
+		return nil
+	}
+}
+
+// WithSecret sets the secret used to sign tokens
+func WithSecret(secret string) Option {
+	return func(cfg *Config) error {
+		cfg.Secret = secret
+		return nil
+	}
+}
+
+// WithMailer sets the mailer used to send emails
+func WithMailer(mailer string) Option {
+	return func(cfg *Config) error {
+		cfg.Mailer = mailer
+		return nil
+	}
+}
+
+// WithMailFrom sets the address emails are sent from
+func WithMailFrom(from string) Option {
+	return func(cfg *Config) error {
+		cfg.MailFrom = from
+		return nil
+	}
+}
+
+// WithPasswordHasher sets the algorithm and parameters used to hash
+// passwords, e.g: argon2id:t=3,m=65536,p=2 or scrypt:N=32768,r=8,p=1
+func WithPasswordHasher(hasher string) Option {
+	return func(cfg *Config) error {
+		cfg.PasswordHasher = hasher
+		return nil
+	}
+}
+
+// WithSessionExpiry sets the lifetime of sessions
+func WithSessionExpiry(expiry time.Duration) Option {
+	return func(cfg *Config) error {
+		cfg.SessionExpiry = expiry
+		return nil
+	}
+}
+
+// WithSessionRememberExpiry sets the lifetime of sessions when the user asks
+// to be remembered
+func WithSessionRememberExpiry(expiry time.Duration) Option {
+	return func(cfg *Config) error {
+		cfg.SessionRememberExpiry = expiry
+		return nil
+	}
+}
+
+// WithOIDC enables logging in with the OpenID Connect provider at issuer
+// using the given client credentials
+func WithOIDC(issuer, clientID, clientSecret string) Option {
+	return func(cfg *Config) error {
+		cfg.OIDCIssuer = issuer
+		cfg.OIDCClientID = clientID
+		cfg.OIDCClientSecret = clientSecret
+		return nil
+	}
+}
+
+// WithOIDCName sets the name of the OpenID Connect provider shown to users
+func WithOIDCName(name string) Option {
+	return func(cfg *Config) error {
+		cfg.OIDCName = name
+		return nil
+	}
+}
+
+// WithOIDCClaims sets the ID token claims users' usernames and email
+// addresses are taken from
+func WithOIDCClaims(usernameClaim, emailClaim string) Option {
+	return func(cfg *Config) error {
+		cfg.OIDCUsernameClaim = usernameClaim
+		cfg.OIDCEmailClaim = emailClaim
+		return nil
+	}
+}
+
+// WithOIDCAutoProvision sets whether accounts are created for users logging
+// in with the OpenID Connect provider for the first time
+func WithOIDCAutoProvision(autoProvision bool) Option {
+	return func(cfg *Config) error {
+		cfg.OIDCAutoProvision = autoProvision
+		return nil
+	}
+}
+
+// WithProxyAuth trusts the username given in header by authenticating reverse
+// proxies connecting from one of the trusted networks (in CIDR notation)
+func WithProxyAuth(header string, trusted []string) Option {
+	return func(cfg *Config) error {
+		cfg.AuthHeader = header
+		cfg.TrustedProxies = trusted
+		return nil
+	}
+}
@@ -0,0 +1,103 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// ErrInvalidHash is returned when a stored argon2id hash cannot be decoded
+var ErrInvalidHash = errors.New("error: invalid password hash")
+
+// Argon2Params are the cost parameters of the argon2id key derivation
+// function, with Memory given in KiB
+type Argon2Params struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+const argon2Prefix = "$argon2id$"
+
+func isArgon2(hash string) bool {
+	return strings.HasPrefix(hash, argon2Prefix)
+}
+
+// generateArgon2 hashes the password with a random salt and encodes it in
+// the PHC string format: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func generateArgon2(password string, params Argon2Params) (string, error) {
+	salt := make([]byte, params.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey(
+		[]byte(password), salt,
+		params.Time, params.Memory, params.Threads, params.KeyLen,
+	)
+
+	return fmt.Sprintf(
+		"%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2Prefix, argon2.Version,
+		params.Memory, params.Time, params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func decodeArgon2(hash string) (params Argon2Params, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	_, err = fmt.Sscanf(
+		parts[3], "m=%d,t=%d,p=%d",
+		&params.Memory, &params.Time, &params.Threads,
+	)
+	if err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	params.SaltLen = uint32(len(salt))
+	params.KeyLen = uint32(len(key))
+
+	return params, salt, key, nil
+}
+
+func compareArgon2(hash, password string) error {
+	params, salt, key, err := decodeArgon2(hash)
+	if err != nil {
+		return err
+	}
+
+	other := argon2.IDKey(
+		[]byte(password), salt,
+		params.Time, params.Memory, params.Threads, params.KeyLen,
+	)
+
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return ErrMismatchedHashAndPassword
+	}
+
+	return nil
+}
@@ -1,72 +1,195 @@
 package password
 
 import (
-	"time"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 
 	scrypt "github.com/elithrar/simple-scrypt"
-	log "github.com/sirupsen/logrus"
 )
 
+// Supported key derivation functions
 const (
-	DefaultMaxTimeout = 500 * time.Millisecond // default max timeout in ms
-	DefaultMaxMemory  = 64                     // default max memory in MB
+	Scrypt   = "scrypt"
+	Argon2id = "argon2id"
+)
+
+// DefaultAlgorithm is the key derivation function used for new passwords
+const DefaultAlgorithm = Argon2id
+
+var (
+	// DefaultScryptParams are the scrypt parameters used unless configured,
+	// consuming approximately 32MB of memory per hash (128 * r * N)
+	DefaultScryptParams = scrypt.Params{N: 32768, R: 8, P: 1, SaltLen: 16, DKLen: 32}
+
+	// DefaultArgon2Params are the argon2id parameters used unless configured,
+	// as recommended by RFC 9106 for memory constrained environments
+	DefaultArgon2Params = Argon2Params{Time: 3, Memory: 64 * 1024, Threads: 2, SaltLen: 16, KeyLen: 32}
+)
+
+var (
+	// ErrMismatchedHashAndPassword is returned by Check when the password
+	// does not match the hash
+	ErrMismatchedHashAndPassword = errors.New("error: password does not match")
+
+	// ErrUnknownAlgorithm is returned for an unsupported key derivation function
+	ErrUnknownAlgorithm = errors.New("error: unknown password hashing algorithm")
+
+	// ErrInvalidParams is returned when a parameter specification is malformed
+	ErrInvalidParams = errors.New("error: invalid password hashing parameters")
 )
 
 // Options ...
 type Options struct {
-	maxTimeout time.Duration
-	maxMemory  int
+	algorithm string
+	scrypt    scrypt.Params
+	argon2    Argon2Params
 }
 
 // NewOptions ...
-func NewOptions(maxTimeout time.Duration, maxMemory int) *Options {
-	return &Options{maxTimeout, maxMemory}
+func NewOptions(algorithm string, scryptParams scrypt.Params, argon2Params Argon2Params) *Options {
+	return &Options{algorithm, scryptParams, argon2Params}
 }
 
-// Manager ...
-type Manager struct {
-	options *Options
-	params  scrypt.Params
-}
+// ParseOptions parses a specification of the form `algorithm[:key=value,...]`
+// such as `argon2id:t=3,m=65536,p=2` or `scrypt:N=32768,r=8,p=1` into Options.
+// Parameters that are not given take their default values.
+func ParseOptions(spec string) (*Options, error) {
+	options := NewOptions(DefaultAlgorithm, DefaultScryptParams, DefaultArgon2Params)
+	if spec == "" {
+		return options, nil
+	}
 
-// NewManager ...
-func NewManager(options *Options) *Manager {
-	if options == nil {
-		options = &Options{}
+	algorithm := spec
+	params := ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		algorithm, params = spec[:i], spec[i+1:]
 	}
+	options.algorithm = algorithm
 
-	if options.maxTimeout == 0 {
-		options.maxTimeout = DefaultMaxTimeout
+	if algorithm != Scrypt && algorithm != Argon2id {
+		return nil, ErrUnknownAlgorithm
 	}
-	if options.maxMemory == 0 {
-		options.maxMemory = DefaultMaxMemory
+
+	for _, param := range strings.Split(params, ",") {
+		if param == "" {
+			continue
+		}
+
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			return nil, ErrInvalidParams
+		}
+
+		n, err := strconv.ParseUint(kv[1], 10, 32)
+		if err != nil || n == 0 {
+			return nil, ErrInvalidParams
+		}
+
+		switch algorithm + ":" + kv[0] {
+		case "scrypt:N":
+			options.scrypt.N = int(n)
+		case "scrypt:r":
+			options.scrypt.R = int(n)
+		case "scrypt:p":
+			options.scrypt.P = int(n)
+		case "argon2id:t":
+			options.argon2.Time = uint32(n)
+		case "argon2id:m":
+			options.argon2.Memory = uint32(n)
+		case "argon2id:p":
+			if n > 255 {
+				return nil, ErrInvalidParams
+			}
+			options.argon2.Threads = uint8(n)
+		default:
+			return nil, ErrInvalidParams
+		}
 	}
 
-	log.Info("Calibrating scrypt parameters ...")
-	params, err := scrypt.Calibrate(
-		options.maxTimeout,
-		options.maxMemory,
-		scrypt.DefaultParams,
-	)
-	if err != nil {
-		log.Fatalf("error calibrating scrypt params: %s", err)
+	switch algorithm {
+	case Scrypt:
+		// N must also be a power of two for the underlying scrypt
+		n := options.scrypt.N
+		if err := options.scrypt.Check(); err != nil || n&(n-1) != 0 {
+			return nil, ErrInvalidParams
+		}
+	case Argon2id:
+		if options.argon2.Memory < 8*uint32(options.argon2.Threads) {
+			return nil, ErrInvalidParams
+		}
+	}
+
+	return options, nil
+}
+
+// String returns the options as a specification understood by ParseOptions
+func (o *Options) String() string {
+	if o.algorithm == Scrypt {
+		return fmt.Sprintf("%s:N=%d,r=%d,p=%d", Scrypt, o.scrypt.N, o.scrypt.R, o.scrypt.P)
 	}
+	return fmt.Sprintf(
+		"%s:t=%d,m=%d,p=%d",
+		Argon2id, o.argon2.Time, o.argon2.Memory, o.argon2.Threads,
+	)
+}
 
-	log.WithField("params", params).Info("scrypt params")
+// Manager ...
+type Manager struct {
+	options *Options
+}
 
-	return &Manager{
-		options,
-		params,
+// NewManager ...
+func NewManager(options *Options) *Manager {
+	if options == nil {
+		options = NewOptions(DefaultAlgorithm, DefaultScryptParams, DefaultArgon2Params)
 	}
+
+	return &Manager{options}
 }
 
 // NewPassword ...
 func (m *Manager) NewPassword(password string) (string, error) {
-	hash, err := scrypt.GenerateFromPassword([]byte(password), m.params)
-	return string(hash), err
+	switch m.options.algorithm {
+	case Scrypt:
+		hash, err := scrypt.GenerateFromPassword([]byte(password), m.options.scrypt)
+		return string(hash), err
+	case Argon2id:
+		return generateArgon2(password, m.options.argon2)
+	default:
+		return "", ErrUnknownAlgorithm
+	}
 }
 
 // Check ...
 func (m *Manager) Check(hash, password string) error {
-	return scrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if isArgon2(hash) {
+		return compareArgon2(hash, password)
+	}
+
+	err := scrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err == scrypt.ErrMismatchedHashAndPassword {
+		return ErrMismatchedHashAndPassword
+	}
+	return err
+}
+
+// NeedsRehash returns whether the hash was created with a different
+// algorithm or parameters than currently configured, in which case it should
+// be replaced the next time the password is known, i.e: on login.
+func (m *Manager) NeedsRehash(hash string) bool {
+	if isArgon2(hash) {
+		if m.options.algorithm != Argon2id {
+			return true
+		}
+		params, _, _, err := decodeArgon2(hash)
+		return err != nil || params != m.options.argon2
+	}
+
+	if m.options.algorithm != Scrypt {
+		return true
+	}
+	params, err := scrypt.Cost([]byte(hash))
+	return err != nil || params != m.options.scrypt
 }
@@ -0,0 +1,118 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	scrypt "github.com/elithrar/simple-scrypt"
+)
+
+// Cheap parameters to keep the tests fast
+var (
+	testScryptParams = scrypt.Params{N: 1024, R: 8, P: 1, SaltLen: 16, DKLen: 32}
+	testArgon2Params = Argon2Params{Time: 1, Memory: 1024, Threads: 1, SaltLen: 16, KeyLen: 32}
+)
+
+func TestManager(t *testing.T) {
+	for _, algorithm := range []string{Scrypt, Argon2id} {
+		t.Run(algorithm, func(t *testing.T) {
+			m := NewManager(NewOptions(algorithm, testScryptParams, testArgon2Params))
+
+			hash, err := m.NewPassword("correct horse")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := m.Check(hash, "correct horse"); err != nil {
+				t.Errorf("expected password to match got %s", err)
+			}
+			if err := m.Check(hash, "battery staple"); err != ErrMismatchedHashAndPassword {
+				t.Errorf("expected ErrMismatchedHashAndPassword got %v", err)
+			}
+			if m.NeedsRehash(hash) {
+				t.Error("expected hash with current params not to need rehashing")
+			}
+		})
+	}
+}
+
+func TestArgon2Format(t *testing.T) {
+	m := NewManager(NewOptions(Argon2id, testScryptParams, testArgon2Params))
+
+	hash, err := m.NewPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("unexpected hash format %q", hash)
+	}
+
+	if err := m.Check("$argon2id$v=19$m=1024,t=1,p=1$bogus", "correct horse"); err != ErrInvalidHash {
+		t.Errorf("expected ErrInvalidHash got %v", err)
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	oldScrypt := NewManager(NewOptions(Scrypt, testScryptParams, testArgon2Params))
+	hash, err := oldScrypt.NewPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newScrypt := testScryptParams
+	newScrypt.N = 2048
+
+	testCases := []struct {
+		name     string
+		manager  *Manager
+		expected bool
+	}{
+		{"same params", oldScrypt, false},
+		{"stronger params", NewManager(NewOptions(Scrypt, newScrypt, testArgon2Params)), true},
+		{"different algorithm", NewManager(NewOptions(Argon2id, testScryptParams, testArgon2Params)), true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := tc.manager.NeedsRehash(hash); actual != tc.expected {
+				t.Errorf("expected NeedsRehash to be %t got %t", tc.expected, actual)
+			}
+			if err := tc.manager.Check(hash, "correct horse"); err != nil {
+				t.Errorf("expected old hash to still verify got %s", err)
+			}
+		})
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	testCases := []struct {
+		spec     string
+		expected string
+		err      error
+	}{
+		{"", "argon2id:t=3,m=65536,p=2", nil},
+		{"argon2id", "argon2id:t=3,m=65536,p=2", nil},
+		{"argon2id:t=2,m=19456,p=1", "argon2id:t=2,m=19456,p=1", nil},
+		{"scrypt", "scrypt:N=32768,r=8,p=1", nil},
+		{"scrypt:N=65536", "scrypt:N=65536,r=8,p=1", nil},
+		{"bcrypt", "", ErrUnknownAlgorithm},
+		{"scrypt:N=1000", "", ErrInvalidParams},
+		{"scrypt:t=3", "", ErrInvalidParams},
+		{"argon2id:m", "", ErrInvalidParams},
+		{"argon2id:m=0", "", ErrInvalidParams},
+		{"argon2id:p=256", "", ErrInvalidParams},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.spec, func(t *testing.T) {
+			options, err := ParseOptions(tc.spec)
+			if err != tc.err {
+				t.Fatalf("expected error %v got %v", tc.err, err)
+			}
+			if err == nil && options.String() != tc.expected {
+				t.Errorf("expected %q got %q", tc.expected, options.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package twtxt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // register GIF decoder
+	_ "image/jpeg" // register JPEG decoder
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"golang.org/x/image/draw"
+)
+
+const (
+	avatarsDir = "avatars"
+
+	// AvatarSize is the width and height avatars are resized to
+	AvatarSize = 256
+
+	// MaxAvatarFileSize is the maximum size of an uploaded avatar in bytes
+	MaxAvatarFileSize = 2 << 20
+
+	// maxAvatarDimension is the maximum width or height of an uploaded
+	// avatar, to refuse decompression bombs before decoding them
+	maxAvatarDimension = 4096
+)
+
+// ErrInvalidAvatar is returned for uploads that are not a supported image
+var ErrInvalidAvatar = errors.New("avatar must be a PNG, JPEG or GIF image of at most 2MB and 4096x4096 pixels")
+
+// URLForAvatar returns the URL of a local user's avatar, versioned by its
+// hash so that caches pick up changes
+func URLForAvatar(baseURL, username, hash string) string {
+	return fmt.Sprintf("%s/user/%s/avatar?v=%s", strings.TrimSuffix(baseURL, "/"), username, hash)
+}
+
+// URLForProfile returns the URL of a local user's profile page
+func URLForProfile(baseURL, username string) string {
+	return fmt.Sprintf("%s/user/%s", strings.TrimSuffix(baseURL, "/"), username)
+}
+
+func avatarPath(path, username string) (string, error) {
+	return securejoin.SecureJoin(filepath.Join(path, avatarsDir), username+".png")
+}
+
+// SaveAvatar decodes the uploaded image, crops it to a square and resizes it
+// to AvatarSize before storing it as PNG, which also strips any metadata it
+// carried. It returns the hash of the stored avatar.
+func SaveAvatar(path, username string, r io.Reader) (string, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, MaxAvatarFileSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > MaxAvatarFileSize {
+		return "", ErrInvalidAvatar
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width > maxAvatarDimension || config.Height > maxAvatarDimension {
+		return "", ErrInvalidAvatar
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", ErrInvalidAvatar
+	}
+
+	// Crop the largest centered square
+	b := src.Bounds()
+	size := b.Dx()
+	if b.Dy() < size {
+		size = b.Dy()
+	}
+	crop := image.Rect(0, 0, size, size).Add(image.Pt(
+		b.Min.X+(b.Dx()-size)/2,
+		b.Min.Y+(b.Dy()-size)/2,
+	))
+
+	dst := image.NewRGBA(image.Rect(0, 0, AvatarSize, AvatarSize))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Join(path, avatarsDir), 0755); err != nil {
+		return "", err
+	}
+
+	fn, err := avatarPath(path, username)
+	if err != nil {
+		return "", err
+	}
+
+	if err := writeFileAtomic(fn, buf.Bytes(), 0644); err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// DeleteAvatar removes the avatar of the given user, if any
+func DeleteAvatar(path, username string) error {
+	fn, err := avatarPath(path, username)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// FeedMetadata returns the metadata comments describing the user at the top
+// of their feed, using the keys of the twtxt metadata extension
+func FeedMetadata(conf *Config, user *User) string {
+	var sb strings.Builder
+
+	field := func(key, value string) {
+		value = strings.Join(strings.Fields(value), " ")
+		if value != "" {
+			fmt.Fprintf(&sb, "# %-12s = %s\n", key, value)
+		}
+	}
+
+	sb.WriteString("#\n")
+	field("nick", user.Username)
+	field("url", URLForUser(conf.BaseURL, user.Username))
+	field("display_name", user.DisplayName)
+	if user.Avatar != "" {
+		field("avatar", URLForAvatar(conf.BaseURL, user.Username, user.Avatar))
+	}
+	field("description", user.Description)
+	if user.Homepage != "" {
+		field("link", "Homepage "+user.Homepage)
+	}
+	field("webmention", URLForWebmention(conf.BaseURL))
+	sb.WriteString("#\n")
+
+	return sb.String()
+}
+
+// ParseFeedMetadata returns the metadata comments `# key = value` of a feed,
+// keeping the first value of keys that appear more than once
+func ParseFeedMetadata(data []byte) map[string]string {
+	metadata := make(map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(line, "#"), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		value := strings.TrimSpace(parts[1])
+		if key == "" || strings.ContainsAny(key, " \t") || value == "" {
+			continue
+		}
+		if _, ok := metadata[key]; !ok {
+			metadata[key] = value
+		}
+	}
+
+	return metadata
+}
+
+// WriteFeedMetadata replaces the comments at the top of the user's feed with
+// their current metadata, creating the feed if it does not exist yet
+func WriteFeedMetadata(conf *Config, user *User) error {
+	feed, err := OpenFeed(conf.Data, user.Username)
+	if err != nil {
+		return err
+	}
+
+	return feed.Update(func(data []byte) ([]byte, error) {
+		// Skip the existing header of comments and blank lines
+		var body bytes.Buffer
+		header := true
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			line := scanner.Text()
+			if header && (line == "" || strings.HasPrefix(line, "#")) {
+				continue
+			}
+			header = false
+			body.WriteString(line)
+			body.WriteString("\n")
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+
+		return append([]byte(FeedMetadata(conf, user)), body.Bytes()...), nil
+	})
+}
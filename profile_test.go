@@ -0,0 +1,113 @@
+package twtxt
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveAvatar(t *testing.T) {
+	dir, err := ioutil.TempDir("", "twtxt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := image.NewRGBA(image.Rect(0, 0, 800, 600))
+	for x := 0; x < 800; x++ {
+		for y := 0; y < 600; y++ {
+			src.Set(x, y, color.RGBA{200, 30, 30, 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := SaveAvatar(dir, "alice", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash == "" {
+		t.Error("expected a hash of the saved avatar")
+	}
+
+	f, err := os.Open(filepath.Join(dir, avatarsDir, "alice.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	avatar, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := avatar.Bounds(); b.Dx() != AvatarSize || b.Dy() != AvatarSize {
+		t.Errorf("expected a %dx%d avatar got %dx%d", AvatarSize, AvatarSize, b.Dx(), b.Dy())
+	}
+
+	if _, err := SaveAvatar(dir, "alice", strings.NewReader("not an image")); err != ErrInvalidAvatar {
+		t.Errorf("expected ErrInvalidAvatar got %v", err)
+	}
+}
+
+func TestWriteFeedMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "twtxt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := &Config{Data: dir, BaseURL: "https://example.com/"}
+	user := &User{
+		Username:    "alice",
+		DisplayName: "Alice",
+		Description: "Hello\nworld",
+		Homepage:    "https://alice.example.com",
+	}
+
+	if err := WriteFeedMetadata(conf, user); err != nil {
+		t.Fatal(err)
+	}
+
+	fn := filepath.Join(dir, feedsDir, "alice")
+	feed := "2020-07-20T12:00:00Z\tfirst\n2020-07-20T13:00:00Z\t# not a comment\n"
+	f, err := os.OpenFile(fn, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(feed)
+	f.Close()
+
+	user.DisplayName = ""
+	user.Avatar = "abcd"
+	if err := WriteFeedMetadata(conf, user); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "#\n" +
+		"# nick         = alice\n" +
+		"# url          = https://example.com/u/alice\n" +
+		"# avatar       = https://example.com/user/alice/avatar?v=abcd\n" +
+		"# description  = Hello world\n" +
+		"# link         = Homepage https://alice.example.com\n" +
+		"# webmention   = https://example.com/webmention\n" +
+		"#\n" +
+		feed
+	if string(data) != expected {
+		t.Errorf("expected feed:\n%s\ngot:\n%s", expected, data)
+	}
+}
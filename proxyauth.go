@@ -0,0 +1,117 @@
+package twtxt
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/prologic/twtxt/session"
+)
+
+// ProxyAuthHandler logs in the user a trusted authenticating reverse proxy
+// says the request is from, creating their account on first sight. Requests
+// without such a user are passed through and authenticate as usual.
+func (s *Server) ProxyAuthHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username := s.am.RemoteUser(r)
+		if username == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sess, ok := r.Context().Value("sesssion").(*session.Session)
+		if !ok {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		if current, _ := sess.Get("username"); strings.EqualFold(current, username) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, err := lookupUser(s.db, username)
+		if err == ErrUserNotFound {
+			user, err = s.provisionProxyUser(username)
+			if err != nil {
+				log.WithError(err).Warnf("error provisioning proxy user %q", username)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			log.Infof("user provisioned: %s", username)
+		} else if err != nil {
+			log.WithError(err).Errorf("error loading proxy user %s", username)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		if user.Disabled {
+			log.Warnf("proxy login attempt for disabled user %s", username)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		sess, err = s.authorize(w, sess, user.Username, false)
+		if err != nil {
+			log.WithError(err).Errorf("error authorizing session for %s", user.Username)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		log.Infof("login successful: %s (via proxy)", user.Username)
+
+		ctx := context.WithValue(r.Context(), "sesssion", sess)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// lookupUser returns the user with the username ignoring case, as proxies
+// may not keep the case a user registered with
+func lookupUser(db Store, username string) (*User, error) {
+	user, err := db.GetUser(username)
+	if err != ErrUserNotFound {
+		return user, err
+	}
+
+	users, err := db.GetAllUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, user := range users {
+		if strings.EqualFold(user.Username, username) {
+			return user, nil
+		}
+	}
+
+	return nil, ErrUserNotFound
+}
+
+// provisionProxyUser creates an account without a password for a user
+// authenticated by a trusted reverse proxy
+func (s *Server) provisionProxyUser(username string) (*User, error) {
+	if err := ValidateUsername(username); err != nil {
+		return nil, err
+	}
+
+	exists, err := UsernameExists(s.db, username)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrUsernameTaken
+	}
+
+	user := &User{
+		Username:  username,
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.db.SetUser(username, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
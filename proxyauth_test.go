@@ -0,0 +1,26 @@
+package twtxt
+
+import (
+	"testing"
+)
+
+func TestLookupUser(t *testing.T) {
+	db, _ := newTestStore(t)
+
+	if err := db.SetUser("alice", &User{Username: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, username := range []string{"alice", "Alice", "ALICE"} {
+		user, err := lookupUser(db, username)
+		if err != nil {
+			t.Errorf("unexpected error looking up %s: %s", username, err)
+		} else if user.Username != "alice" {
+			t.Errorf("expected alice for %s got %s", username, user.Username)
+		}
+	}
+
+	if _, err := lookupUser(db, "bob"); err != ErrUserNotFound {
+		t.Errorf("expected ErrUserNotFound got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package twtxt
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prologic/twtxt/session"
+)
+
+// reauthKey is the session key of the user who last logged in again with the
+// OpenID Connect provider, and when
+const reauthKey = "reauth"
+
+// reauthWindow is how long logging in again with the OpenID Connect provider
+// confirms a user without a password for a sensitive action
+const reauthWindow = 5 * time.Minute
+
+// reauthPaths are the pages a user may be sent back to after logging in
+// again with the OpenID Connect provider
+var reauthPaths = map[string]bool{
+	"/settings/2fa":    true,
+	"/settings/delete": true,
+}
+
+// setReauthenticated records in the session that the user logged in again
+// with the OpenID Connect provider just now
+func setReauthenticated(sess *session.Session, username string) {
+	sess.Set(reauthKey, fmt.Sprintf("%d %s", time.Now().Unix(), username))
+}
+
+// reauthenticated reports whether a user without a password has proven it
+// is them again, either by a trusted proxy vouching for the request or by
+// logging in again with the OpenID Connect provider recently
+func (s *Server) reauthenticated(r *http.Request, user *User) bool {
+	if username := s.am.RemoteUser(r); username != "" {
+		return strings.EqualFold(username, user.Username)
+	}
+
+	sess, ok := r.Context().Value("sesssion").(*session.Session)
+	if !ok {
+		return false
+	}
+
+	value, _ := sess.Get(reauthKey)
+	parts := strings.SplitN(value, " ", 2)
+	if len(parts) != 2 || parts[1] != user.Username {
+		return false
+	}
+
+	n, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return false
+	}
+
+	return time.Since(time.Unix(n, 0)) < reauthWindow
+}
+
+// confirmUser reports whether the request confirms it is from the user
+// before a sensitive action, with their password or, for users without one
+// who login with a provider, by reauthenticating there. Logging in again is
+// only good for one action.
+func (s *Server) confirmUser(r *http.Request, user *User) bool {
+	if user.Password != "" {
+		return s.pm.Check(user.Password, r.FormValue("password")) == nil
+	}
+
+	ok := s.reauthenticated(r, user)
+
+	if sess, found := r.Context().Value("sesssion").(*session.Session); found {
+		if _, set := sess.Get(reauthKey); set {
+			sess.Delete(reauthKey)
+		}
+	}
+
+	return ok
+}
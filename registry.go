@@ -0,0 +1,282 @@
+package twtxt
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// MaxFeedURLLength is the longest URL of a feed that can be registered
+	MaxFeedURLLength = 256
+
+	// MaxRegisteredFeeds is the number of feeds nobody here follows that can
+	// be registered through the registry API at any one time
+	MaxRegisteredFeeds = 1000
+
+	// RegisteredFeedTTL is how long a registered feed nobody here follows is
+	// kept without being updated
+	RegisteredFeedTTL = 30 * 24 * time.Hour
+)
+
+var (
+	// ErrInvalidFeed is returned when registering a feed without a valid nick
+	// or http(s) URL
+	ErrInvalidFeed = errors.New("error: feeds must have a nick and a http(s) URL")
+
+	// ErrFeedNotVerified is returned when registering a feed that does not
+	// declare the nick or URL it is registered with
+	ErrFeedNotVerified = errors.New("error: feeds must declare their nick or url in their metadata")
+
+	// ErrRegistryFull is returned when registering a feed while there are
+	// MaxRegisteredFeeds already
+	ErrRegistryFull = errors.New("error: too many feeds registered")
+)
+
+// knownFeedKey returns the key of the feed at the given URL in the registry,
+// the same for all URLs that normalize to the same one
+func knownFeedKey(url string) string {
+	if norm := NormalizeURL(url); norm != "" {
+		url = norm
+	}
+	return tokenKey(url)
+}
+
+// ValidateFeed checks that a feed has a nick that would be a valid username
+// and a http(s) URL of at most MaxFeedURLLength
+func ValidateFeed(nick, url string) error {
+	if len(nick) < MinUsernameLength || len(nick) > MaxUsernameLength || !validUsername.MatchString(nick) {
+		return ErrInvalidFeed
+	}
+	if len(url) > MaxFeedURLLength {
+		return ErrInvalidFeed
+	}
+	if _, ok := safeURL(url); !ok {
+		return ErrInvalidFeed
+	}
+	return nil
+}
+
+// VerifyFeed fetches the feed at url and checks that whoever runs it wants
+// it registered, by it declaring the nick or url it is registered with in
+// its metadata
+func VerifyFeed(nick, url string) error {
+	_, data, err := fetchFeed(url)
+	if err != nil {
+		return err
+	}
+
+	metadata := ParseFeedMetadata(data)
+	if metadata["nick"] == nick {
+		return nil
+	}
+	if declared := metadata["url"]; declared != "" && NormalizeURL(declared) == NormalizeURL(url) {
+		return nil
+	}
+
+	return ErrFeedNotVerified
+}
+
+// RegisterFeed records a feed in the registry on behalf of someone, e.g:
+// through the registry API, so that it is kept even if nobody follows it.
+// Feeds registered from elsewhere should be checked with VerifyFeed first.
+func RegisterFeed(db Store, nick, url string) (*KnownFeed, error) {
+	if err := ValidateFeed(nick, url); err != nil {
+		return nil, err
+	}
+
+	key := knownFeedKey(url)
+
+	feed, err := db.GetKnownFeed(key)
+	if err == ErrFeedNotFound {
+		feeds, err := db.GetAllKnownFeeds()
+		if err != nil {
+			return nil, err
+		}
+
+		registered := 0
+		for _, feed := range feeds {
+			if feed.Registered && !feed.Local && feed.Followers == 0 {
+				registered++
+			}
+		}
+		if registered >= MaxRegisteredFeeds {
+			return nil, ErrRegistryFull
+		}
+
+		feed = &KnownFeed{Nick: nick, URL: url, CreatedAt: time.Now()}
+	} else if err != nil {
+		return nil, err
+	}
+	feed.Registered = true
+
+	if err := db.SetKnownFeed(key, feed); err != nil {
+		return nil, err
+	}
+
+	return feed, nil
+}
+
+// UpdateRegistry records the feeds of all local users and every feed they
+// follow along with their number of followers, and refreshes the
+// descriptions and last updates of feeds in the cache. Feeds nobody follows
+// anymore are dropped unless they were registered and updated within the
+// RegisteredFeedTTL.
+func UpdateRegistry(conf *Config, db Store, cache Cache) error {
+	users, err := db.GetAllUsers()
+	if err != nil {
+		return err
+	}
+
+	existing, err := db.GetAllKnownFeeds()
+	if err != nil {
+		return err
+	}
+
+	feeds := make(map[string]*KnownFeed)
+	for _, feed := range existing {
+		feed.Local = false
+		feed.Followers = 0
+		feeds[knownFeedKey(feed.URL)] = feed
+	}
+
+	record := func(nick, url string) *KnownFeed {
+		key := knownFeedKey(url)
+		feed, ok := feeds[key]
+		if !ok {
+			feed = &KnownFeed{Nick: nick, URL: url, CreatedAt: time.Now()}
+			feeds[key] = feed
+		}
+		return feed
+	}
+
+	// Name followed feeds by the nick most of their followers know them by
+	nicks := make(map[string]map[string]int)
+
+	for _, user := range users {
+		if user.Disabled {
+			continue
+		}
+
+		feed := record(user.Username, URLForUser(conf.BaseURL, user.Username))
+		feed.Nick = user.Username
+		feed.Description = user.Description
+		feed.Local = true
+
+		if f, err := OpenFeed(conf.Data, user.Username); err == nil {
+			if stat, err := os.Stat(f.Path()); err == nil {
+				feed.LastUpdated = stat.ModTime()
+			}
+		}
+
+		for nick, url := range user.Following {
+			if _, ok := safeURL(url); !ok {
+				continue
+			}
+
+			feed := record(nick, url)
+			feed.Followers++
+
+			key := knownFeedKey(url)
+			if nicks[key] == nil {
+				nicks[key] = make(map[string]int)
+			}
+			nicks[key][nick]++
+		}
+	}
+
+	for url, cached := range cache {
+		feed, ok := feeds[knownFeedKey(url)]
+		if !ok || feed.Local {
+			continue
+		}
+
+		if description := cached.Metadata["description"]; description != "" {
+			feed.Description = description
+		}
+		for _, tweet := range cached.Tweets {
+			if tweet.Created.After(feed.LastUpdated) {
+				feed.LastUpdated = tweet.Created
+			}
+		}
+	}
+
+	now := time.Now()
+
+	for key, feed := range feeds {
+		alive := feed.CreatedAt
+		if feed.LastUpdated.After(alive) {
+			alive = feed.LastUpdated
+		}
+		expired := now.Sub(alive) > RegisteredFeedTTL
+
+		if !feed.Local && feed.Followers == 0 && (!feed.Registered || expired) {
+			if err := db.DelKnownFeed(key); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !feed.Local {
+			best := 0
+			for nick, n := range nicks[key] {
+				if n > best || (n == best && nick < feed.Nick) {
+					feed.Nick, best = nick, n
+				}
+			}
+		}
+
+		if err := db.SetKnownFeed(key, feed); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SearchRegistry returns the feeds in the registry whose nick, URL or
+// description contain the query, ignoring case, most followed first
+func SearchRegistry(db Store, query string) ([]*KnownFeed, error) {
+	feeds, err := db.GetAllKnownFeeds()
+	if err != nil {
+		return nil, err
+	}
+
+	query = strings.ToLower(strings.TrimSpace(query))
+
+	var matches []*KnownFeed
+	for _, feed := range feeds {
+		if query == "" ||
+			strings.Contains(strings.ToLower(feed.Nick), query) ||
+			strings.Contains(strings.ToLower(feed.URL), query) ||
+			strings.Contains(strings.ToLower(feed.Description), query) {
+			matches = append(matches, feed)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Followers != matches[j].Followers {
+			return matches[i].Followers > matches[j].Followers
+		}
+		return matches[i].Nick < matches[j].Nick
+	})
+
+	return matches, nil
+}
+
+// updateRegistry is UpdateRegistry with the current cache
+func updateRegistry(conf *Config, db Store) {
+	cache, err := LoadCache(conf.Data)
+	if err != nil {
+		log.WithError(err).Warn("error loading cache to update registry")
+		cache = make(Cache)
+	}
+
+	if err := UpdateRegistry(conf, db, cache); err != nil {
+		log.WithError(err).Warn("error updating registry")
+	}
+}
@@ -0,0 +1,181 @@
+package twtxt
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpdateRegistry(t *testing.T) {
+	db, dir := newTestStore(t)
+
+	conf := &Config{Data: dir, BaseURL: "https://example.com"}
+
+	users := []*User{
+		{
+			Username:    "alice",
+			Description: "Hi, I'm Alice",
+			Following: map[string]string{
+				"bob":   "https://bob.example.org/twtxt.txt",
+				"carol": "https://example.com/u/carol",
+			},
+		},
+		{
+			Username: "carol",
+			Following: map[string]string{
+				"bobby": "http://bob.example.org/twtxt.txt",
+				"evil":  "javascript:alert(1)",
+			},
+		},
+		{Username: "mallory", Disabled: true},
+	}
+	for _, user := range users {
+		if err := db.SetUser(user.Username, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := RegisterFeed(db, "dave", "https://dave.example.org/twtxt.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RegisterFeed(db, "evil", "javascript:alert(1)"); err != ErrInvalidFeed {
+		t.Errorf("expected ErrInvalidFeed got %v", err)
+	}
+
+	updated := time.Date(2020, 7, 20, 12, 0, 0, 0, time.UTC)
+	cache := Cache{
+		"https://bob.example.org/twtxt.txt": {
+			Tweets:   Tweets{{Created: updated.Add(-time.Hour)}, {Created: updated}},
+			Metadata: map[string]string{"description": "Bob's feed"},
+		},
+	}
+
+	if err := UpdateRegistry(conf, db, cache); err != nil {
+		t.Fatal(err)
+	}
+
+	feeds, err := SearchRegistry(db, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byNick := make(map[string]*KnownFeed)
+	for _, feed := range feeds {
+		byNick[feed.Nick] = feed
+	}
+	if len(feeds) != 4 {
+		t.Fatalf("expected 4 feeds got %d: %+v", len(feeds), byNick)
+	}
+
+	if bob := byNick["bob"]; bob == nil || bob.Followers != 2 || bob.Description != "Bob's feed" || !bob.LastUpdated.Equal(updated) {
+		t.Errorf("unexpected feed of bob %+v", bob)
+	}
+	if carol := byNick["carol"]; carol == nil || !carol.Local || carol.Followers != 1 {
+		t.Errorf("unexpected feed of carol %+v", carol)
+	}
+	if alice := byNick["alice"]; alice == nil || !alice.Local || alice.Description != "Hi, I'm Alice" {
+		t.Errorf("unexpected feed of alice %+v", alice)
+	}
+	if dave := byNick["dave"]; dave == nil || !dave.Registered {
+		t.Errorf("unexpected feed of dave %+v", dave)
+	}
+	if feeds[0].Nick != "bob" {
+		t.Errorf("expected most followed feed first got %s", feeds[0].Nick)
+	}
+
+	// Unfollowed feeds are dropped
+	users[0].Following = nil
+	users[1].Following = nil
+	for _, user := range users[:2] {
+		if err := db.SetUser(user.Username, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := UpdateRegistry(conf, db, cache); err != nil {
+		t.Fatal(err)
+	}
+
+	feeds, err = SearchRegistry(db, "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(feeds) != 1 || feeds[0].Nick != "dave" {
+		t.Errorf("expected only the registered feed to remain got %+v", feeds)
+	}
+
+	// Registered feeds nobody follows expire when they are not updated
+	stale := &KnownFeed{
+		Nick:       "stale",
+		URL:        "https://stale.example.org/twtxt.txt",
+		Registered: true,
+		CreatedAt:  time.Now().Add(-RegisteredFeedTTL - time.Hour),
+	}
+	if err := db.SetKnownFeed(knownFeedKey(stale.URL), stale); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateRegistry(conf, db, cache); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetKnownFeed(knownFeedKey(stale.URL)); err != ErrFeedNotFound {
+		t.Errorf("expected stale registered feed to expire got %v", err)
+	}
+}
+
+func TestValidateFeed(t *testing.T) {
+	testCases := []struct {
+		nick, url string
+		valid     bool
+	}{
+		{"bob", "https://bob.example.org/twtxt.txt", true},
+		{"bob_2", "http://bob.example.org/twtxt.txt", true},
+		{"", "https://bob.example.org/twtxt.txt", false},
+		{"bob\r", "https://bob.example.org/twtxt.txt", false},
+		{"bob\tsmith", "https://bob.example.org/twtxt.txt", false},
+		{strings.Repeat("b", MaxUsernameLength+1), "https://bob.example.org/twtxt.txt", false},
+		{"bob", "https://bob.example.org/" + strings.Repeat("x", MaxFeedURLLength), false},
+		{"bob", "javascript:alert(1)", false},
+		{"bob", "/twtxt.txt", false},
+	}
+
+	for _, tc := range testCases {
+		if err := ValidateFeed(tc.nick, tc.url); (err == nil) != tc.valid {
+			t.Errorf("ValidateFeed(%q, %q): expected valid %v got %v", tc.nick, tc.url, tc.valid, err)
+		}
+	}
+}
+
+func TestVerifyFeed(t *testing.T) {
+	defer allowPrivateAddresses()()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/nick.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "# nick = bob\n2020-07-20T12:00:00Z\thello\n")
+	})
+	mux.HandleFunc("/url.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "# url = http://%s/url.txt\n", r.Host)
+	})
+	mux.HandleFunc("/plain.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "2020-07-20T12:00:00Z\thello\n")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	if err := VerifyFeed("bob", server.URL+"/nick.txt"); err != nil {
+		t.Errorf("expected feed declaring its nick to verify got %v", err)
+	}
+	if err := VerifyFeed("someone", server.URL+"/url.txt"); err != nil {
+		t.Errorf("expected feed declaring its url to verify got %v", err)
+	}
+	if err := VerifyFeed("alice", server.URL+"/nick.txt"); err != ErrFeedNotVerified {
+		t.Errorf("expected ErrFeedNotVerified for another nick got %v", err)
+	}
+	if err := VerifyFeed("bob", server.URL+"/plain.txt"); err != ErrFeedNotVerified {
+		t.Errorf("expected ErrFeedNotVerified for a feed without metadata got %v", err)
+	}
+	if err := VerifyFeed("bob", server.URL+"/missing.txt"); err == nil {
+		t.Error("expected an error for a missing feed")
+	}
+}
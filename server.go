@@ -1,8 +1,14 @@
 package twtxt
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	rice "github.com/GeertJohan/go.rice"
 	"github.com/NYTimes/gziphandler"
@@ -70,6 +76,114 @@ type Server struct {
 
 	// Passwords
 	pm *password.Manager
+
+	// OpenID Connect
+	oidc *auth.OIDCProvider
+
+	// Mailer
+	mailer Mailer
+
+	// Rate limiting
+	ipLimiter      *Limiter
+	userLimiter    *Limiter
+	requestLimiter *Limiter
+
+	// registryLimiter limits feeds registered through the registry API,
+	// which other clients and servers may do several of in a row
+	registryLimiter *Limiter
+
+	// webmentionLimiter limits webmentions received from other servers,
+	// which send one for every tweet mentioning our users
+	webmentionLimiter *Limiter
+}
+
+// authorize logs the user into the session after rotating its id to prevent
+// session fixation, returning the new session
+func (s *Server) authorize(w http.ResponseWriter, sess *session.Session, username string, remember bool) (*session.Session, error) {
+	expiry := s.config.SessionExpiry
+	if remember {
+		expiry = s.config.SessionRememberExpiry
+	}
+
+	sess, err := s.sm.Rotate(w, sess, expiry)
+	if err != nil {
+		return nil, err
+	}
+
+	sess.Set(session.CreatedAtKey, strconv.FormatInt(time.Now().Unix(), 10))
+	sess.Set("username", username)
+
+	return sess, nil
+}
+
+// maxFormOverhead is the room left for the other fields and the multipart
+// framing of a form next to an uploaded file
+const maxFormOverhead = 1 << 20
+
+// bodyLimits are the largest request bodies accepted by endpoints taking
+// uploads, so that oversized forms are refused before they are parsed and
+// spooled to disk
+var bodyLimits = map[string]int64{
+	"/post":             MaxMediaFileSize + maxFormOverhead,
+	"/settings/profile": MaxAvatarFileSize + maxFormOverhead,
+}
+
+// BodyLimitHandler caps the size of request bodies of endpoints taking
+// uploads. It must come before anything parsing forms, such as the
+// CSRFHandler.
+func (s *Server) BodyLimitHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit, ok := bodyLimits[r.URL.Path]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.ContentLength > limit {
+			ctx := &Context{
+				Error:   true,
+				Message: fmt.Sprintf("Uploads may be at most %d MB", (limit-maxFormOverhead)>>20),
+			}
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			s.render("error", w, ctx)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimited renders a 429 Too Many Requests response and returns true if
+// any of the given keys must wait before making another attempt
+func (s *Server) rateLimited(w http.ResponseWriter, r *http.Request, l *Limiter, keys ...string) bool {
+	var wait time.Duration
+	for _, key := range keys {
+		if d := l.Wait(key); d > wait {
+			wait = d
+		}
+	}
+
+	if wait == 0 {
+		return false
+	}
+
+	SecurityEvent(r, "rate-limited", r.FormValue("username"))
+
+	wait = wait.Round(time.Second)
+	if wait < time.Second {
+		wait = time.Second
+	}
+
+	ctx := &Context{
+		Error:   true,
+		Message: fmt.Sprintf("Too many attempts, please try again in %s", wait),
+	}
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", int(wait.Seconds())))
+	w.WriteHeader(http.StatusTooManyRequests)
+	s.render("error", w, ctx)
+
+	return true
 }
 
 func (s *Server) render(name string, w http.ResponseWriter, ctx *Context) {
@@ -97,7 +211,13 @@ func (s *Server) ListenAndServe() {
 			}).Handler(
 				gziphandler.GzipHandler(
 					s.sm.Handler(
-						s.router,
+						s.ProxyAuthHandler(
+							s.BodyLimitHandler(
+								s.CSRFHandler(
+									s.router,
+								),
+							),
+						),
 					),
 				),
 			),
@@ -168,15 +288,47 @@ func (s *Server) initRoutes() {
 
 	s.router.GET("/", s.TimelineHandler())
 	s.router.POST("/post", s.am.MustAuth(s.PostHandler()))
+	s.router.GET("/edit/:hash", s.am.MustAuth(s.EditTweetHandler()))
+	s.router.POST("/edit/:hash", s.am.MustAuth(s.EditTweetHandler()))
+	s.router.POST("/delete/:hash", s.am.MustAuth(s.DeleteTweetHandler()))
+
+	s.router.GET("/mentions", s.am.MustAuth(s.MentionsHandler()))
+	s.router.GET("/lookup", s.am.MustAuth(s.LookupHandler()))
+
+	s.router.GET("/drafts", s.am.MustAuth(s.DraftsHandler()))
+	s.router.POST("/drafts", s.am.MustAuth(s.DraftsHandler()))
+	s.router.POST("/drafts/publish", s.am.MustAuth(s.PublishDraftHandler()))
+	s.router.POST("/drafts/delete", s.am.MustAuth(s.DeleteDraftHandler()))
 	s.router.HEAD("/u/:nick", s.TwtxtHandler())
 	s.router.GET("/u/:nick", s.TwtxtHandler())
 
+	s.router.GET("/user/:nick", s.ProfileHandler())
+	s.router.GET("/user/:nick/avatar", s.AvatarHandler())
+
+	s.router.GET("/media/:name", s.MediaHandler())
+
+	s.router.GET("/tag/:tag", s.TagHandler())
+	s.router.GET("/discover", s.DiscoverHandler())
+
+	s.router.GET("/api/plain/users", s.APIUsersHandler())
+	s.router.POST("/api/plain/users", s.APIUsersHandler())
+	s.router.GET("/api/plain/tweets", s.APITweetsHandler())
+	s.router.GET("/api/plain/mentions", s.APIMentionsHandler())
+	s.router.GET("/api/plain/tags/:tag", s.APITagsHandler())
+
+	s.router.POST("/webmention", s.WebmentionHandler())
+
 	s.router.GET("/login", s.LoginHandler())
 	s.router.POST("/login", s.LoginHandler())
 
-	s.router.GET("/logout", s.LogoutHandler())
 	s.router.POST("/logout", s.LogoutHandler())
 
+	s.router.GET("/login/oidc", s.OIDCLoginHandler())
+	s.router.GET("/login/oidc/callback", s.OIDCCallbackHandler())
+
+	s.router.GET("/login/2fa", s.LoginTwoFactorHandler())
+	s.router.POST("/login/2fa", s.LoginTwoFactorHandler())
+
 	s.router.GET("/register", s.RegisterHandler())
 	s.router.POST("/register", s.RegisterHandler())
 
@@ -186,23 +338,163 @@ func (s *Server) initRoutes() {
 	s.router.GET("/import", s.am.MustAuth(s.ImportHandler()))
 	s.router.POST("/import", s.am.MustAuth(s.ImportHandler()))
 
-	s.router.GET("/unfollow", s.am.MustAuth(s.UnfollowHandler()))
 	s.router.POST("/unfollow", s.am.MustAuth(s.UnfollowHandler()))
 
 	s.router.GET("/settings", s.am.MustAuth(s.SettingsHandler()))
 	s.router.POST("/settings", s.am.MustAuth(s.SettingsHandler()))
+
+	s.router.GET("/invites", s.am.MustAuth(s.InvitesHandler()))
+	s.router.POST("/invites", s.am.MustAuth(s.InvitesHandler()))
+	s.router.POST("/invites/revoke", s.am.MustAuth(s.RevokeInviteHandler()))
+
+	s.router.GET("/verify", s.VerifyHandler())
+	s.router.POST("/verify/resend", s.am.MustAuth(s.ResendVerificationHandler()))
+
+	s.router.GET("/forgot", s.ForgotPasswordHandler())
+	s.router.POST("/forgot", s.ForgotPasswordHandler())
+
+	s.router.GET("/reset", s.ResetPasswordHandler())
+	s.router.POST("/reset", s.ResetPasswordHandler())
+
+	s.router.POST("/settings/profile", s.am.MustAuth(s.ProfileSettingsHandler()))
+	s.router.POST("/settings/sessions/revoke", s.am.MustAuth(s.RevokeSessionHandler()))
+
+	s.router.GET("/settings/2fa", s.am.MustAuth(s.TwoFactorSettingsHandler()))
+	s.router.POST("/settings/2fa", s.am.MustAuth(s.TwoFactorSettingsHandler()))
+	s.router.POST("/settings/2fa/disable", s.am.MustAuth(s.DisableTwoFactorHandler()))
+
+	s.router.GET("/settings/export", s.am.MustAuth(s.ExportHandler()))
+	s.router.GET("/settings/delete", s.am.MustAuth(s.DeleteAccountHandler()))
+	s.router.POST("/settings/delete", s.am.MustAuth(s.DeleteAccountHandler()))
+
+	s.router.GET("/admin", s.MustAdmin(s.AdminHandler()))
+	s.router.POST("/admin/disable", s.MustAdmin(s.AdminDisableHandler(true)))
+	s.router.POST("/admin/enable", s.MustAdmin(s.AdminDisableHandler(false)))
+	s.router.POST("/admin/delete", s.MustAdmin(s.AdminDeleteHandler()))
+	s.router.POST("/admin/reset", s.MustAdmin(s.AdminResetHandler()))
+}
+
+// MustAdmin ensures the request is made by an authenticated administrator
+func (s *Server) MustAdmin(next httprouter.Handle) httprouter.Handle {
+	return s.am.MustAuth(func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s.config, s.db, r)
+		if !ctx.IsAdmin {
+			log.Warnf("non-admin user %s denied access to %s", ctx.Username, r.URL.Path)
+			s.NotFoundHandler(w, r)
+			return
+		}
+		next(w, r, p)
+	})
+}
+
+// deleteUser removes a user's feed, sessions and account record
+func (s *Server) deleteUser(user *User) error {
+	if err := DeleteFeed(s.config.Data, user); err != nil {
+		return err
+	}
+
+	if err := s.sm.Purge("username", user.Username); err != nil {
+		return err
+	}
+
+	if err := DeleteUserDrafts(s.db, user.Username); err != nil {
+		return err
+	}
+
+	if err := DeleteUserMentions(s.db, user.Username); err != nil {
+		return err
+	}
+
+	if err := DeleteAvatar(s.config.Data, user.Username); err != nil {
+		return err
+	}
+
+	if err := UnlinkUserIdentities(s.db, user.Username); err != nil {
+		return err
+	}
+
+	return s.db.DelUser(user.Username)
+}
+
+// writeSecret writes the secret to a new file only readable by us, so that it
+// never shows up in logs
+func writeSecret(fn, secret string) error {
+	if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.WriteString(secret); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func (s *Server) setupAdminUser() error {
+	username := s.config.AdminUser
+	if username == "" {
+		return nil
+	}
+
+	user, err := s.db.GetUser(username)
+	if err == ErrUserNotFound {
+		password, err := GenerateRandomToken(12)
+		if err != nil {
+			return err
+		}
+
+		hash, err := s.pm.NewPassword(password)
+		if err != nil {
+			return err
+		}
+
+		user = &User{
+			Username:  username,
+			Password:  hash,
+			CreatedAt: time.Now(),
+		}
+
+		fn := filepath.Join(s.config.Data, "admin_password")
+		if err := writeSecret(fn, password+"\n"); err != nil {
+			return err
+		}
+
+		log.Warnf(
+			"created admin user %s with the password in %s (change it in /settings and delete the file)",
+			username, fn,
+		)
+	} else if err != nil {
+		return err
+	} else if user.Admin {
+		return nil
+	}
+
+	user.Admin = true
+	if err := s.db.SetUser(username, user); err != nil {
+		return err
+	}
+
+	AuditLog(s.config.Data, "system", "grant-admin", username)
+
+	return nil
 }
 
 // NewServer ...
 func NewServer(bind string, options ...Option) (*Server, error) {
-	templates, err := NewTemplates()
+	config := NewConfig()
+
+	templates, err := NewTemplates(config)
 	if err != nil {
 		log.WithError(err).Error("error loading templates")
 		return nil, err
 	}
 
-	config := NewConfig()
-
 	router := NewRouter()
 
 	server := &Server{
@@ -214,17 +506,13 @@ func NewServer(bind string, options ...Option) (*Server, error) {
 		// Schedular
 		cron: cron.New(),
 
-		// Auth
-		am: auth.NewManager(auth.NewOptions("/login", "/register")),
+		// Rate limiting
+		ipLimiter:      NewLimiter(20, 15*time.Minute, 15*time.Minute),
+		userLimiter:    NewLimiter(5, 15*time.Minute, 15*time.Minute),
+		requestLimiter: NewLimiter(10, time.Hour, time.Hour),
 
-		// Sessions
-		sm: session.NewManager(
-			session.NewOptions("twtxt", "mysecret"),
-			session.NewMemoryStore(-1),
-		),
-
-		// Passwords
-		pm: password.NewManager(nil),
+		registryLimiter:   NewRateLimiter(60, time.Hour),
+		webmentionLimiter: NewRateLimiter(600, time.Hour),
 	}
 
 	for _, opt := range options {
@@ -233,6 +521,66 @@ func NewServer(bind string, options ...Option) (*Server, error) {
 		}
 	}
 
+	if err := server.config.LoadSecret(); err != nil {
+		log.WithError(err).Error("error loading secret")
+		return nil, err
+	}
+
+	authOptions := auth.NewOptions("/login", "/register")
+	if server.config.AuthHeader != "" {
+		trusted, err := auth.ParseCIDRs(server.config.TrustedProxies)
+		if err != nil {
+			log.WithError(err).Error("error parsing trusted proxies")
+			return nil, err
+		}
+		if len(trusted) == 0 {
+			log.Warn("no trusted proxies configured, ignoring auth header")
+		}
+		authOptions.WithProxyAuth(server.config.AuthHeader, trusted)
+	}
+	server.am = auth.NewManager(authOptions)
+
+	passwordOptions, err := password.ParseOptions(server.config.PasswordHasher)
+	if err != nil {
+		log.WithError(err).Errorf("error parsing password hasher %q", server.config.PasswordHasher)
+		return nil, err
+	}
+	server.pm = password.NewManager(passwordOptions)
+	log.Infof("hashing passwords with %s", passwordOptions)
+
+	server.sm = session.NewManager(
+		session.NewOptions(
+			"twtxt",
+			server.config.Secret,
+			server.config.IsSecure(),
+			server.config.SessionExpiry,
+		),
+		session.NewMemoryStore(server.config.SessionExpiry),
+	)
+
+	if server.config.OIDCEnabled() {
+		oidc, err := auth.NewOIDCProvider(context.Background(), auth.NewOIDCOptions(
+			server.config.OIDCIssuer,
+			server.config.OIDCClientID,
+			server.config.OIDCClientSecret,
+			strings.TrimSuffix(server.config.BaseURL, "/")+"/login/oidc/callback",
+			server.config.OIDCUsernameClaim,
+			server.config.OIDCEmailClaim,
+		))
+		if err != nil {
+			log.WithError(err).Error("error setting up oidc provider")
+			return nil, err
+		}
+		server.oidc = oidc
+	}
+
+	mailer, err := NewMailer(server.config.Mailer, server.config.MailFrom)
+	if err != nil {
+		log.WithError(err).Error("error creating mailer")
+		return nil, err
+	}
+	server.mailer = mailer
+
 	db, err := NewStore(server.config.Store)
 	if err != nil {
 		log.WithError(err).Error("error creating store")
@@ -240,6 +588,14 @@ func NewServer(bind string, options ...Option) (*Server, error) {
 	}
 	server.db = db
 
+	if err := server.setupAdminUser(); err != nil {
+		log.WithError(err).Error("error setting up admin user")
+		return nil, err
+	}
+
+	// Let feeds of our own users be fetched even at a private address
+	trustLocalHost(server.config.BaseURL)
+
 	if err := server.setupCronJobs(); err != nil {
 		log.WithError(err).Error("error settupt up background jobs")
 		return nil, err
@@ -247,6 +603,9 @@ func NewServer(bind string, options ...Option) (*Server, error) {
 	server.cron.Start()
 	log.Infof("started background jobs")
 
+	// Don't wait for the first feed update to fill the registry
+	go updateRegistry(server.config, server.db)
+
 	server.initRoutes()
 
 	return server, nil
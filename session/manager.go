@@ -2,7 +2,11 @@ package session
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/andreadipersio/securecookie"
@@ -12,6 +16,18 @@ import (
 // Data ...
 type Data map[string]string
 
+// Keys of the metadata recorded in every session's data
+const (
+	CreatedAtKey  = "created_at"
+	LastSeenAtKey = "last_seen_at"
+	RemoteAddrKey = "remote_addr"
+	UserAgentKey  = "user_agent"
+)
+
+// lastSeenInterval is how often a session's last seen time is updated, so
+// that not every request results in a write to the store
+const lastSeenInterval = time.Minute
+
 // Session ...
 type Session struct {
 	sid  SessionID
@@ -35,6 +51,74 @@ func (s *Session) Get(key string) (string, bool) {
 // Delete ...
 func (s *Session) Delete(key string) {
 	delete(s.data, key)
+	s.store.Save(s.sid, s.data)
+}
+
+// Hash returns a hash of the session id that identifies the session
+// without revealing the id itself, e.g. to revoke it from another session
+func (s *Session) Hash() string {
+	sum := sha256.Sum256([]byte(s.sid))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreatedAt returns when the session was created
+func (s *Session) CreatedAt() time.Time {
+	return s.getTime(CreatedAtKey)
+}
+
+// LastSeenAt returns when the session was last used
+func (s *Session) LastSeenAt() time.Time {
+	return s.getTime(LastSeenAtKey)
+}
+
+// RemoteAddr returns the address the session was last used from
+func (s *Session) RemoteAddr() string {
+	return s.data[RemoteAddrKey]
+}
+
+// UserAgent returns the user agent the session was last used with
+func (s *Session) UserAgent() string {
+	return s.data[UserAgentKey]
+}
+
+func (s *Session) getTime(key string) time.Time {
+	n, err := strconv.ParseInt(s.data[key], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(n, 0)
+}
+
+// touch records the request's metadata in the session, saving it only if
+// something changed or the last seen time is stale
+func (s *Session) touch(r *http.Request) {
+	now := time.Now()
+
+	remoteAddr, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remoteAddr = r.RemoteAddr
+	}
+
+	changed := false
+	set := func(key, value string) {
+		if s.data[key] != value {
+			s.data[key] = value
+			changed = true
+		}
+	}
+
+	if _, ok := s.data[CreatedAtKey]; !ok {
+		set(CreatedAtKey, strconv.FormatInt(now.Unix(), 10))
+	}
+	if now.Sub(s.LastSeenAt()) >= lastSeenInterval {
+		set(LastSeenAtKey, strconv.FormatInt(now.Unix(), 10))
+	}
+	set(RemoteAddrKey, remoteAddr)
+	set(UserAgentKey, r.UserAgent())
+
+	if changed {
+		s.store.Save(s.sid, s.data)
+	}
 }
 
 // NewSession ...
@@ -50,11 +134,13 @@ func NewSession(sid SessionID, store Store) *Session {
 type Options struct {
 	name   string
 	secret string
+	secure bool
+	expiry time.Duration
 }
 
 // NewOptions ...
-func NewOptions(name, secret string) *Options {
-	return &Options{name, secret}
+func NewOptions(name, secret string, secure bool, expiry time.Duration) *Options {
+	return &Options{name, secret, secure, expiry}
 }
 
 // Manager ...
@@ -76,18 +162,58 @@ func (m *Manager) Create(w http.ResponseWriter) (SessionID, error) {
 		return "", err
 	}
 
+	m.setCookie(w, sid, m.options.expiry)
+
+	return sid, nil
+}
+
+func (m *Manager) setCookie(w http.ResponseWriter, sid SessionID, expiry time.Duration) {
 	cookie := &http.Cookie{
 		Name:     m.options.name,
 		Value:    sid.String(),
-		Secure:   false,
+		Path:     "/",
+		Secure:   m.options.secure,
 		HttpOnly: true,
-		MaxAge:   3600,
-		Expires:  time.Now().Add(1 * time.Hour),
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(expiry.Seconds()),
+		Expires:  time.Now().Add(expiry),
 	}
 
 	securecookie.SetSecureCookie(w, m.options.secret, cookie)
+}
 
-	return sid, nil
+// Rotate replaces the session's id with a new one, carrying over its data,
+// to prevent session fixation when a user logs in. The new session and its
+// cookie are valid for expiry, or the default expiry if zero.
+func (m *Manager) Rotate(w http.ResponseWriter, sess *Session, expiry time.Duration) (*Session, error) {
+	if expiry == 0 {
+		expiry = m.options.expiry
+	}
+
+	sid, err := NewSessionID(m.options.secret)
+	if err != nil {
+		log.WithError(err).Error("error creating new session")
+		return nil, err
+	}
+
+	data := make(Data)
+	for key, value := range sess.data {
+		data[key] = value
+	}
+
+	if err := m.store.Save(sid, data); err != nil {
+		return nil, err
+	}
+	if err := m.store.Expire(sid, expiry); err != nil {
+		return nil, err
+	}
+	if err := m.store.Delete(sess.sid); err != nil {
+		return nil, err
+	}
+
+	m.setCookie(w, sid, expiry)
+
+	return &Session{sid, data, m.store}, nil
 }
 
 // Validate ....
@@ -125,7 +251,7 @@ func (m *Manager) GetOrCreate(w http.ResponseWriter, r *http.Request) (SessionID
 
 // Delete ...
 func (m *Manager) Delete(w http.ResponseWriter, r *http.Request) {
-	if sess := r.Context().Value("sessin"); sess != nil {
+	if sess := r.Context().Value("sesssion"); sess != nil {
 		sid := sess.(*Session).sid
 		m.store.Delete(sid)
 	}
@@ -133,8 +259,10 @@ func (m *Manager) Delete(w http.ResponseWriter, r *http.Request) {
 	cookie := &http.Cookie{
 		Name:     m.options.name,
 		Value:    "",
-		Secure:   false,
+		Path:     "/",
+		Secure:   m.options.secure,
 		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
 		MaxAge:   -1,
 		Expires:  time.Now(),
 	}
@@ -142,6 +270,57 @@ func (m *Manager) Delete(w http.ResponseWriter, r *http.Request) {
 	securecookie.SetSecureCookie(w, m.options.secret, cookie)
 }
 
+// Find returns every session whose data has `key` set to `value`, for
+// example all sessions belonging to a given user.
+func (m *Manager) Find(key, value string) ([]*Session, error) {
+	sids, err := m.store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []*Session
+	for _, sid := range sids {
+		data := make(Data)
+		// Don't keep every session alive just by listing them
+		if err := m.store.Peek(sid, &data); err != nil {
+			continue
+		}
+		if data[key] == value {
+			sessions = append(sessions, &Session{sid, data, m.store})
+		}
+	}
+
+	return sessions, nil
+}
+
+// Revoke deletes the session from the store, logging out whoever holds it
+func (m *Manager) Revoke(sess *Session) error {
+	return m.store.Delete(sess.sid)
+}
+
+// Purge deletes every session whose data has `key` set to `value`, except
+// for any sessions given in `keep`.
+func (m *Manager) Purge(key, value string, keep ...*Session) error {
+	sessions, err := m.Find(key, value)
+	if err != nil {
+		return err
+	}
+
+next:
+	for _, sess := range sessions {
+		for _, k := range keep {
+			if k != nil && k.sid == sess.sid {
+				continue next
+			}
+		}
+		if err := m.Revoke(sess); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Handler ...
 func (m *Manager) Handler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -153,6 +332,7 @@ func (m *Manager) Handler(next http.Handler) http.Handler {
 		}
 
 		sesssion := NewSession(sid, m.store)
+		sesssion.touch(r)
 
 		ctx := context.WithValue(r.Context(), "sesssion", sesssion)
 
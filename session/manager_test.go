@@ -0,0 +1,102 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestManagerHandler(t *testing.T) {
+	store := NewMemoryStore(30 * time.Minute)
+	m := NewManager(NewOptions("test", testSigningKey, false, time.Hour), store)
+
+	var sess *Session
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sess = r.Context().Value("sesssion").(*Session)
+		if r.URL.Path == "/logout" {
+			m.Delete(w, r)
+		}
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("User-Agent", "test-agent")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if sess.RemoteAddr() != "192.0.2.1" {
+		t.Errorf("expected remote addr 192.0.2.1 got %q", sess.RemoteAddr())
+	}
+	if sess.UserAgent() != "test-agent" {
+		t.Errorf("expected user agent test-agent got %q", sess.UserAgent())
+	}
+	if sess.CreatedAt().IsZero() || sess.LastSeenAt().IsZero() {
+		t.Error("expected created and last seen times to be set")
+	}
+
+	sid := sess.sid
+	if err := store.Get(sid, &Data{}); err != nil {
+		t.Fatalf("expected session to be saved: %s", err)
+	}
+
+	r = httptest.NewRequest("GET", "/logout", nil)
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if sess.sid != sid {
+		t.Fatal("expected the existing session to be used")
+	}
+	if err := store.Get(sid, &Data{}); err != ErrStateNotFound {
+		t.Errorf("expected session to be deleted on logout got %v", err)
+	}
+}
+
+func TestManagerPurge(t *testing.T) {
+	store := NewMemoryStore(30 * time.Minute)
+	m := NewManager(NewOptions("test", testSigningKey, false, time.Hour), store)
+
+	newSession := func(username string) *Session {
+		sid, err := NewSessionID(testSigningKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sess := NewSession(sid, store)
+		sess.Set("username", username)
+		return sess
+	}
+
+	a1, a2, a3 := newSession("alice"), newSession("alice"), newSession("alice")
+	b := newSession("bob")
+
+	sessions, err := m.Find("username", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 3 {
+		t.Fatalf("expected 3 sessions for alice got %d", len(sessions))
+	}
+
+	if err := m.Purge("username", "alice", a1); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err = m.Find("username", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].Hash() != a1.Hash() {
+		t.Errorf("expected only the kept session to remain got %d sessions", len(sessions))
+	}
+
+	for _, sess := range []*Session{a2, a3} {
+		if err := store.Get(sess.sid, &Data{}); err != ErrStateNotFound {
+			t.Errorf("expected purged session to be deleted got %v", err)
+		}
+	}
+	if err := store.Get(b.sid, &Data{}); err != nil {
+		t.Errorf("expected other user's session to remain got %v", err)
+	}
+}
@@ -11,7 +11,15 @@ import (
 //This should be used only for testing and prototyping.
 //Production systems should use a shared server store like redis
 type MemoryStore struct {
-	entries *cache.Cache
+	entries  *cache.Cache
+	duration time.Duration
+}
+
+//entry is the state data of a session along with how long it is kept
+//in the store without being accessed
+type entry struct {
+	state    []byte
+	duration time.Duration
 }
 
 //NewMemoryStore constructs and returns a new MemoryStore
@@ -20,7 +28,8 @@ func NewMemoryStore(sessionDuration time.Duration) *MemoryStore {
 		sessionDuration = DefaultSessionDuration
 	}
 	return &MemoryStore{
-		entries: cache.New(sessionDuration, time.Minute),
+		entries:  cache.New(sessionDuration, time.Minute),
+		duration: sessionDuration,
 	}
 }
 
@@ -32,7 +41,11 @@ func (ms *MemoryStore) Save(sid SessionID, state interface{}) error {
 	if nil != err {
 		return err
 	}
-	ms.entries.Set(sid.String(), j, cache.DefaultExpiration)
+	duration := ms.duration
+	if e, found := ms.entries.Get(sid.String()); found {
+		duration = e.(entry).duration
+	}
+	ms.entries.Set(sid.String(), entry{j, duration}, duration)
 	return nil
 }
 
@@ -40,11 +53,33 @@ func (ms *MemoryStore) Save(sid SessionID, state interface{}) error {
 //and populates the `data` parameter with it. This will also
 //reset the data's time to live in the store.
 func (ms *MemoryStore) Get(sid SessionID, state interface{}) error {
-	j, found := ms.entries.Get(sid.String())
+	e, found := ms.entries.Get(sid.String())
 	if !found {
 		return ErrStateNotFound
 	}
-	return json.Unmarshal(j.([]byte), state)
+	ms.entries.Set(sid.String(), e, e.(entry).duration)
+	return json.Unmarshal(e.(entry).state, state)
+}
+
+//Peek retrieves the previously saved state data for the session id like
+//Get, without resetting the data's time to live in the store.
+func (ms *MemoryStore) Peek(sid SessionID, state interface{}) error {
+	e, found := ms.entries.Get(sid.String())
+	if !found {
+		return ErrStateNotFound
+	}
+	return json.Unmarshal(e.(entry).state, state)
+}
+
+//Expire sets how long the state data for the session id is kept in the
+//store without being accessed.
+func (ms *MemoryStore) Expire(sid SessionID, duration time.Duration) error {
+	e, found := ms.entries.Get(sid.String())
+	if !found {
+		return ErrStateNotFound
+	}
+	ms.entries.Set(sid.String(), entry{e.(entry).state, duration}, duration)
+	return nil
 }
 
 //Delete deletes all state data associated with the session id from the store.
@@ -52,3 +87,12 @@ func (ms *MemoryStore) Delete(sid SessionID) error {
 	ms.entries.Delete(sid.String())
 	return nil
 }
+
+//List returns the ids of all unexpired sessions held in the store.
+func (ms *MemoryStore) List() ([]SessionID, error) {
+	var sids []SessionID
+	for key := range ms.entries.Items() {
+		sids = append(sids, SessionID(key))
+	}
+	return sids, nil
+}
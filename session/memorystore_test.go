@@ -20,7 +20,7 @@ func TestMemStore(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	memstore := NewMemStore(30 * time.Minute)
+	memstore := NewMemoryStore(30 * time.Minute)
 	err = memstore.Save(sid, state)
 	if nil != err {
 		t.Fatal(err)
@@ -50,3 +50,45 @@ func TestMemStore(t *testing.T) {
 		t.Errorf("found deleted session data in store:\n got %v", state3)
 	}
 }
+
+func TestMemStorePeek(t *testing.T) {
+	type State struct {
+		Requests int
+	}
+
+	sid, err := NewSessionID(testSigningKey)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	memstore := NewMemoryStore(time.Hour)
+	if err := memstore.Save(sid, &State{Requests: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, saved, _ := memstore.entries.GetWithExpiration(sid.String())
+
+	time.Sleep(10 * time.Millisecond)
+
+	state := &State{}
+	if err := memstore.Peek(sid, state); err != nil {
+		t.Fatal(err)
+	}
+	if state.Requests != 1 {
+		t.Errorf("peeked state did not match saved state: got %v", state)
+	}
+
+	_, peeked, _ := memstore.entries.GetWithExpiration(sid.String())
+	if !peeked.Equal(saved) {
+		t.Errorf("expected peeking to keep the expiry %v, got %v", saved, peeked)
+	}
+
+	if err := memstore.Get(sid, state); err != nil {
+		t.Fatal(err)
+	}
+
+	_, got, _ := memstore.entries.GetWithExpiration(sid.String())
+	if !got.After(saved) {
+		t.Errorf("expected getting to extend the expiry %v, got %v", saved, got)
+	}
+}
@@ -28,6 +28,17 @@ type Store interface {
 	//reset the data's time to live in the store.
 	Get(sid SessionID, state interface{}) error
 
+	//Peek is like Get but leaves the data's time to live untouched, for
+	//looking sessions up without keeping them alive.
+	Peek(sid SessionID, state interface{}) error
+
+	//Expire sets how long the state data for the session id is kept in the
+	//store without being accessed.
+	Expire(sid SessionID, duration time.Duration) error
+
 	//Delete deletes all state data associated with the session id from the store.
 	Delete(sid SessionID) error
+
+	//List returns the ids of all sessions currently held in the store.
+	List() ([]SessionID, error)
 }
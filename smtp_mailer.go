@@ -0,0 +1,45 @@
+package twtxt
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/url"
+)
+
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func newSMTPMailer(path, from string) (*SMTPMailer, error) {
+	u, err := url.Parse(fmt.Sprintf("smtp://%s", path))
+	if err != nil {
+		return nil, err
+	}
+
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		host, port = u.Host, "25"
+	}
+
+	mailer := &SMTPMailer{
+		addr: net.JoinHostPort(host, port),
+		from: from,
+	}
+
+	if u.User != nil {
+		password, _ := u.User.Password()
+		mailer.auth = smtp.PlainAuth("", u.User.Username(), password, host)
+	}
+
+	return mailer, nil
+}
+
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	return smtp.SendMail(
+		m.addr, m.auth, m.from, []string{to},
+		formatEmail(m.from, to, subject, body),
+	)
+}
@@ -0,0 +1,99 @@
+/*!
+ * Mention autocomplete
+ *
+ * Suggests feeds to mention while typing "@nick" in a textarea with a
+ * data-mentions attribute, using the /lookup endpoint.
+ */
+
+(function() {
+
+  var lookupURL = '/lookup';
+  var minLength = 1;
+
+  function currentMention(textarea) {
+    var before = textarea.value.slice(0, textarea.selectionStart);
+    var match = before.match(/(^|\s)@([_a-zA-Z0-9]*)$/);
+    if (!match) {
+      return null;
+    }
+    return {
+      prefix: match[2],
+      start: before.length - match[2].length - 1
+    };
+  }
+
+  function insertMention(textarea, mention, nick) {
+    var value = textarea.value;
+    var end = textarea.selectionStart;
+    var text = '@' + nick + ' ';
+    textarea.value = value.slice(0, mention.start) + text + value.slice(end);
+    textarea.selectionStart = textarea.selectionEnd = mention.start + text.length;
+    textarea.focus();
+  }
+
+  function setup(textarea) {
+    var list = document.createElement('ul');
+    list.className = 'mention-suggestions';
+    list.hidden = true;
+    textarea.parentNode.insertBefore(list, textarea.nextSibling);
+
+    var pending = null;
+
+    function clear() {
+      list.hidden = true;
+      while (list.firstChild) {
+        list.removeChild(list.firstChild);
+      }
+    }
+
+    function show(mention, suggestions) {
+      clear();
+      suggestions.forEach(function(suggestion) {
+        var item = document.createElement('li');
+        var link = document.createElement('a');
+        link.href = '#';
+        link.textContent = '@' + suggestion.nick;
+        link.title = suggestion.url;
+        link.addEventListener('mousedown', function(event) {
+          event.preventDefault();
+          insertMention(textarea, mention, suggestion.nick);
+          clear();
+        });
+        item.appendChild(link);
+
+        var url = document.createElement('small');
+        url.textContent = ' ' + suggestion.url;
+        item.appendChild(url);
+
+        list.appendChild(item);
+      });
+      list.hidden = suggestions.length === 0;
+    }
+
+    textarea.addEventListener('input', function() {
+      var mention = currentMention(textarea);
+      if (!mention || mention.prefix.length < minLength) {
+        clear();
+        return;
+      }
+
+      if (pending) {
+        pending.abort();
+      }
+      pending = new XMLHttpRequest();
+      pending.open('GET', lookupURL + '?q=' + encodeURIComponent(mention.prefix));
+      pending.responseType = 'json';
+      pending.onload = function() {
+        if (this.status === 200 && Array.isArray(this.response)) {
+          show(mention, this.response);
+        }
+      };
+      pending.send();
+    });
+
+    textarea.addEventListener('blur', clear);
+  }
+
+  document.querySelectorAll('textarea[data-mentions]').forEach(setup);
+
+})();
@@ -9,16 +9,55 @@ var (
 	ErrInvalidStore   = errors.New("error: invalid store")
 	ErrUserNotFound   = errors.New("error: user not found")
 	ErrInvalidSession = errors.New("error: invalid session")
+	ErrTokenNotFound  = errors.New("error: token not found")
+	ErrInviteNotFound = errors.New("error: invite not found")
+
+	ErrIdentityNotFound = errors.New("error: identity not found")
+	ErrDraftNotFound    = errors.New("error: draft not found")
+	ErrMentionNotFound  = errors.New("error: mention not found")
+	ErrFeedNotFound     = errors.New("error: feed not found")
 )
 
 type Store interface {
 	GetUser(username string) (*User, error)
 	SetUser(username string, user *User) error
+	DelUser(username string) error
 
 	GetAllUsers() ([]*User, error)
 
 	GetSession(sid string) (*Session, error)
 	SetSession(sid string, session *Session) error
+
+	GetToken(key string) (*Token, error)
+	SetToken(key string, token *Token) error
+	DelToken(key string) error
+
+	GetInvite(code string) (*Invite, error)
+	SetInvite(code string, invite *Invite) error
+	DelInvite(code string) error
+	GetAllInvites() ([]*Invite, error)
+
+	GetIdentity(key string) (*Identity, error)
+	SetIdentity(key string, identity *Identity) error
+	DelIdentity(key string) error
+	GetAllIdentities() ([]*Identity, error)
+
+	GetDraft(id string) (*Draft, error)
+	SetDraft(id string, draft *Draft) error
+	DelDraft(id string) error
+	GetAllDrafts() ([]*Draft, error)
+
+	GetMention(username, id string) (*Mention, error)
+	SetMention(username, id string, mention *Mention) error
+	DelMention(username, id string) error
+	GetMentions(username string) ([]*Mention, error)
+	GetUnreadMentions(username string) (int, error)
+	SetUnreadMentions(username string, unread int) error
+
+	GetKnownFeed(key string) (*KnownFeed, error)
+	SetKnownFeed(key string, feed *KnownFeed) error
+	DelKnownFeed(key string) error
+	GetAllKnownFeeds() ([]*KnownFeed, error)
 }
 
 func NewStore(store string) (Store, error) {
@@ -26,12 +26,14 @@ type Templates struct {
 	templates map[string]*template.Template
 }
 
-func NewTemplates() (*Templates, error) {
+func NewTemplates(conf *Config) (*Templates, error) {
 	templates := make(map[string]*template.Template)
 
 	funcMap := map[string]interface{}{
-		"Time":           humanize.Time,
-		"FormatMentions": FormatMentions,
+		"Time": humanize.Time,
+		"FormatTweet": func(text string) template.HTML {
+			return FormatTweet(conf.BaseURL, text)
+		},
 	}
 
 	box, err := rice.FindBox("templates")
@@ -0,0 +1,125 @@
+package twtxt
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+const (
+	// TokenVerify is the kind of token used to verify email addresses
+	TokenVerify = "verify"
+
+	// TokenReset is the kind of token used to reset forgotten passwords
+	TokenReset = "reset"
+
+	tokenLength  = 32
+	signedLength = tokenLength + sha256.Size
+)
+
+var (
+	ErrInvalidToken = errors.New("error: invalid or expired token")
+)
+
+// NewSignedToken returns a new random token signed with secret
+func NewSignedToken(secret string) (string, error) {
+	buf := make([]byte, signedLength)
+	if _, err := rand.Read(buf[:tokenLength]); err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(buf[:tokenLength])
+	copy(buf[tokenLength:], mac.Sum(nil))
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// ValidateSignedToken checks that token was signed with secret
+func ValidateSignedToken(token, secret string) error {
+	buf, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(buf) != signedLength {
+		return ErrInvalidToken
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(buf[:tokenLength])
+	if !hmac.Equal(mac.Sum(nil), buf[tokenLength:]) {
+		return ErrInvalidToken
+	}
+
+	return nil
+}
+
+// tokenKey returns the key a token is stored under so that a copy of the
+// store does not contain usable tokens
+func tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// IssueToken creates a new signed token of the given kind for user that
+// expires after ttl and persists it in the store
+func IssueToken(db Store, secret, kind string, user *User, ttl time.Duration) (string, error) {
+	value, err := NewSignedToken(secret)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	token := &Token{
+		Kind:      kind,
+		Username:  user.Username,
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	if err := db.SetToken(tokenKey(value), token); err != nil {
+		return "", err
+	}
+
+	return value, nil
+}
+
+// LookupToken validates the signature of value and returns the unexpired
+// token of the given kind it refers to
+func LookupToken(db Store, secret, kind, value string) (*Token, error) {
+	if err := ValidateSignedToken(value, secret); err != nil {
+		return nil, err
+	}
+
+	token, err := db.GetToken(tokenKey(value))
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if token.Kind != kind {
+		return nil, ErrInvalidToken
+	}
+
+	if time.Now().After(token.ExpiresAt) {
+		_ = db.DelToken(tokenKey(value))
+		return nil, ErrInvalidToken
+	}
+
+	return token, nil
+}
+
+// RedeemToken is like LookupToken but also deletes the token so that it can
+// only be used once
+func RedeemToken(db Store, secret, kind, value string) (*Token, error) {
+	token, err := LookupToken(db, secret, kind, value)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.DelToken(tokenKey(value)); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
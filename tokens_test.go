@@ -0,0 +1,79 @@
+package twtxt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokens(t *testing.T) {
+	db, _ := newTestStore(t)
+
+	const secret = "secret"
+	user := &User{Username: "alice", Email: "alice@example.com"}
+
+	value, err := IssueToken(db, secret, TokenReset, user, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tampered tokens and tokens signed with another secret are rejected
+	tampered := []byte(value)
+	if tampered[0] == 'A' {
+		tampered[0] = 'B'
+	} else {
+		tampered[0] = 'A'
+	}
+	if _, err := LookupToken(db, secret, TokenReset, string(tampered)); err != ErrInvalidToken {
+		t.Errorf("expected ErrInvalidToken for a tampered token got %v", err)
+	}
+	if _, err := LookupToken(db, "other", TokenReset, value); err != ErrInvalidToken {
+		t.Errorf("expected ErrInvalidToken for another secret got %v", err)
+	}
+	if _, err := LookupToken(db, secret, TokenReset, "garbage"); err != ErrInvalidToken {
+		t.Errorf("expected ErrInvalidToken for garbage got %v", err)
+	}
+
+	// Tokens of another kind are rejected
+	if _, err := LookupToken(db, secret, TokenVerify, value); err != ErrInvalidToken {
+		t.Errorf("expected ErrInvalidToken for another kind got %v", err)
+	}
+
+	// Looking a token up does not use it
+	if _, err := LookupToken(db, secret, TokenReset, value); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := RedeemToken(db, secret, TokenReset, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Username != user.Username || token.Email != user.Email {
+		t.Errorf("unexpected token %+v", token)
+	}
+
+	// Tokens can only be used once
+	if _, err := RedeemToken(db, secret, TokenReset, value); err != ErrInvalidToken {
+		t.Errorf("expected ErrInvalidToken for a reused token got %v", err)
+	}
+
+	// Signed but unknown tokens are rejected
+	unknown, err := NewSignedToken(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LookupToken(db, secret, TokenReset, unknown); err != ErrInvalidToken {
+		t.Errorf("expected ErrInvalidToken for an unknown token got %v", err)
+	}
+
+	// Expired tokens are rejected and deleted
+	expired, err := IssueToken(db, secret, TokenVerify, user, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RedeemToken(db, secret, TokenVerify, expired); err != ErrInvalidToken {
+		t.Errorf("expected ErrInvalidToken for an expired token got %v", err)
+	}
+	if _, err := db.GetToken(tokenKey(expired)); err == nil {
+		t.Error("expected expired token to be deleted")
+	}
+}
@@ -0,0 +1,113 @@
+// Package totp implements time-based one-time passwords as described in
+// RFC 6238 and compatible with common authenticator apps.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultDigits is the number of digits in a code
+	DefaultDigits = 6
+
+	// DefaultPeriod is the number of seconds a code is valid for
+	DefaultPeriod = 30
+
+	// DefaultSkew is the number of periods either side of the current one
+	// that are also accepted to allow for clock drift
+	DefaultSkew = 1
+
+	secretLength = 20
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a new random base32 encoded secret
+func GenerateSecret() (string, error) {
+	buf := make([]byte, secretLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(buf), nil
+}
+
+// Step returns the time step t falls in
+func Step(t time.Time) int64 {
+	return t.Unix() / DefaultPeriod
+}
+
+// CodeAt returns the code for the given secret and time step
+func CodeAt(secret string, step int64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimSpace(secret)))
+	if err != nil {
+		return "", err
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < DefaultDigits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", DefaultDigits, value%mod), nil
+}
+
+// Validate checks code against the secret at time t allowing for clock skew
+// and returns the time step it matched, so that callers can reject codes
+// that have already been used
+func Validate(secret, code string, t time.Time) (int64, bool) {
+	code = strings.Replace(strings.TrimSpace(code), " ", "", -1)
+	if len(code) != DefaultDigits {
+		return 0, false
+	}
+
+	current := Step(t)
+	for step := current - DefaultSkew; step <= current+DefaultSkew; step++ {
+		expected, err := CodeAt(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// ProvisioningURI returns the otpauth:// URI used by authenticator apps to
+// enroll the secret, usually presented as a QR code
+func ProvisioningURI(issuer, account, secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprintf("%d", DefaultDigits))
+	params.Set("period", fmt.Sprintf("%d", DefaultPeriod))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: params.Encode(),
+	}
+	return u.String()
+}
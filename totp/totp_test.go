@@ -0,0 +1,75 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 Appendix B test vectors for SHA1, truncated to 6 digits
+func TestCodeAt(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).
+		EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, test := range tests {
+		code, err := CodeAt(secret, Step(time.Unix(test.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != test.code {
+			t.Errorf("at %d expected %s got %s", test.unix, test.code, code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	code, err := CodeAt(secret, Step(now.Add(-DefaultPeriod*time.Second)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	step, ok := Validate(secret, code, now)
+	if !ok {
+		t.Fatal("expected code from previous period to validate")
+	}
+	if step != Step(now)-1 {
+		t.Errorf("expected matched step %d got %d", Step(now)-1, step)
+	}
+
+	if _, ok := Validate(secret, code, now.Add(5*DefaultPeriod*time.Second)); ok {
+		t.Error("expected stale code not to validate")
+	}
+
+	if _, ok := Validate(secret, "abc", now); ok {
+		t.Error("expected malformed code not to validate")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("twtxt.net", "bob", "JBSWY3DPEHPK3PXP")
+	if !strings.HasPrefix(uri, "otpauth://totp/twtxt.net:bob?") {
+		t.Errorf("unexpected provisioning uri %s", uri)
+	}
+	if !strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") {
+		t.Errorf("provisioning uri %s is missing the secret", uri)
+	}
+}
@@ -4,6 +4,10 @@ package twtxt
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -11,14 +15,24 @@ import (
 	"regexp"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	log "github.com/sirupsen/logrus"
 )
 
 const (
 	feedsDir = "feeds"
+
+	// TombstoneText replaces the text of deleted tweets that leave a
+	// tombstone, so clients that already fetched them see they are gone
+	TombstoneText = "(deleted)"
 )
 
+// ErrTweetNotFound is returned when editing or deleting a tweet that is not
+// in the user's feed
+var ErrTweetNotFound = errors.New("error: tweet not found")
+
 type Tweeter struct {
 	Nick string
 	URL  string
@@ -30,6 +44,15 @@ type Tweet struct {
 	Created time.Time
 }
 
+// Hash returns a short identifier of the tweet derived from its feed, time
+// and text, used to refer to it when editing or deleting it
+func (tweet Tweet) Hash() string {
+	payload := tweet.Tweeter.URL + "\n" + tweet.Created.Format(time.RFC3339) + "\n" + tweet.Text
+	sum := sha256.Sum256([]byte(payload))
+	hash := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(sum[:])
+	return strings.ToLower(hash[:7])
+}
+
 // typedef to be able to attach sort methods
 type Tweets []Tweet
 
@@ -45,57 +68,181 @@ func (tweets Tweets) Swap(i, j int) {
 
 func (tweets Tweets) Tags() map[string]int {
 	tags := make(map[string]int)
-	re := regexp.MustCompile(`#[-\w]+`)
 	for _, tweet := range tweets {
-		for _, tag := range re.FindAllString(tweet.Text, -1) {
-			tags[strings.TrimLeft(tag, "#")]++
+		for _, tag := range tweet.Tags() {
+			tags[tag]++
 		}
 	}
 	return tags
 }
 
-// Turns "@nick" into "@<nick URL>" if we're following nick.
-func ExpandMentions(text string, user *User) string {
-	re := regexp.MustCompile(`@([_a-zA-Z0-9]+)`)
-	return re.ReplaceAllStringFunc(text, func(match string) string {
-		parts := re.FindStringSubmatch(match)
-		mentionednick := parts[1]
+// CleanTweetText trims the text and joins its lines, as each tweet must be a
+// single line of the feed
+func CleanTweetText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// CollapseMentions turns "@<nick URL>" back into "@nick", the reverse of
+// ResolveMentions, e.g: to edit a tweet
+func CollapseMentions(text string) string {
+	re := regexp.MustCompile(`@<([^ >]+) *[^>]*>`)
+	return re.ReplaceAllString(text, "@$1")
+}
 
-		for followednick, followedurl := range user.Following {
-			if mentionednick == followednick {
-				return fmt.Sprintf("@<%s %s>", followednick, followedurl)
-			}
+// nickMentionPattern matches "@nick" at the start of the text or after a
+// space, so that e.g: email addresses are left alone
+var nickMentionPattern = regexp.MustCompile(`(^|\s)@([_a-zA-Z0-9]+)`)
+
+// endsNick reports whether the rune may follow a mentioned nick, which is
+// any space or punctuation, e.g: "@bob’s", but those that could continue
+// it such as "@bob-smith" or "@bob@example.com"
+func endsNick(r rune) bool {
+	return unicode.IsSpace(r) || (unicode.IsPunct(r) && !strings.ContainsRune("-@/", r))
+}
+
+// expandMentions turns "@nick" into "@<nick URL>" for every nick resolve
+// returns the URL of
+func expandMentions(text string, resolve func(nick string) (string, bool)) string {
+	var sb strings.Builder
+
+	last := 0
+	for _, m := range nickMentionPattern.FindAllStringSubmatchIndex(text, -1) {
+		nick := text[m[4]:m[5]]
+
+		// Don't split a longer nick, e.g: "@bob" out of "@bob-smith"
+		if r, _ := utf8.DecodeRuneInString(text[m[1]:]); m[1] < len(text) && !endsNick(r) {
+			continue
 		}
-		// Not expanding if we're not following
-		return match
-	})
+
+		url, ok := resolve(nick)
+		if !ok {
+			continue
+		}
+
+		sb.WriteString(text[last:m[4]])
+		fmt.Fprintf(&sb, "<%s %s>", nick, url)
+		last = m[5]
+	}
+	sb.WriteString(text[last:])
+
+	return sb.String()
 }
 
-func AppendTweet(path, text string, user *User) error {
-	p := filepath.Join(path, feedsDir)
-	if err := os.MkdirAll(p, 0755); err != nil {
-		log.WithError(err).Error("error creating feeds directory")
-		return err
+// AppendTweet posts a new tweet to the user's feed and returns it. Mentions
+// in the text are posted as is, see ResolveMentions.
+func AppendTweet(conf *Config, text string, user *User) (Tweet, error) {
+	text = CleanTweetText(text)
+	if text == "" {
+		return Tweet{}, fmt.Errorf("cowardly refusing to tweet empty text, or only spaces")
+	}
+
+	feed, err := OpenFeed(conf.Data, user.Username)
+	if err != nil {
+		return Tweet{}, err
+	}
+
+	tweet := Tweet{
+		Tweeter: Tweeter{
+			Nick: user.Username,
+			URL:  URLForUser(conf.BaseURL, user.Username),
+		},
+		Text:    text,
+		Created: time.Now().Truncate(time.Second),
 	}
 
-	fn := filepath.Join(p, user.Username)
-	text = strings.TrimSpace(text)
+	line := fmt.Sprintf("%s\t%s\n", tweet.Created.Format(time.RFC3339), tweet.Text)
+	if err := feed.Append([]byte(line)); err != nil {
+		log.WithError(err).Errorf("error appending tweet to feed of %s", user.Username)
+		return Tweet{}, err
+	}
+
+	return tweet, nil
+}
+
+// EditTweet replaces the text of the user's tweet with the given hash,
+// keeping its original time, and returns the tweet as it was and as edited.
+// Mentions in the text are posted as is, see ResolveMentions.
+func EditTweet(conf *Config, user *User, hash, text string) (Tweet, Tweet, error) {
+	text = CleanTweetText(text)
 	if text == "" {
-		return fmt.Errorf("cowardly refusing to tweet empty text, or only spaces")
+		return Tweet{}, Tweet{}, fmt.Errorf("cowardly refusing to tweet empty text, or only spaces")
+	}
+
+	var old, edited Tweet
+	err := rewriteTweet(conf, user, hash, func(tweet Tweet, created string) string {
+		old, edited = tweet, tweet
+		edited.Text = text
+		return fmt.Sprintf("%s\t%s\n", created, edited.Text)
+	})
+	if err != nil {
+		return Tweet{}, Tweet{}, err
 	}
 
-	text = fmt.Sprintf("%s\t%s\n", time.Now().Format(time.RFC3339), ExpandMentions(text, user))
-	f, err := os.OpenFile(fn, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	return old, edited, nil
+}
+
+// DeleteTweet removes the user's tweet with the given hash from their feed,
+// or replaces its text with TombstoneText if tombstone is true
+func DeleteTweet(conf *Config, user *User, hash string, tombstone bool) error {
+	return rewriteTweet(conf, user, hash, func(_ Tweet, created string) string {
+		if tombstone {
+			return fmt.Sprintf("%s\t%s\n", created, TombstoneText)
+		}
+		return ""
+	})
+}
+
+// rewriteTweet atomically replaces the line of the user's tweet with the
+// given hash by the result of replace, called with the tweet and its raw time
+func rewriteTweet(conf *Config, user *User, hash string, replace func(tweet Tweet, created string) string) error {
+	feed, err := OpenFeed(conf.Data, user.Username)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	if _, err = f.WriteString(text); err != nil {
+	tweeter := Tweeter{
+		Nick: user.Username,
+		URL:  URLForUser(conf.BaseURL, user.Username),
+	}
+
+	return feed.Update(func(data []byte) ([]byte, error) {
+		var buf bytes.Buffer
+		found := false
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			line := scanner.Text()
+
+			if !found && line != "" && !strings.HasPrefix(line, "#") {
+				if tweets, err := ParseFile(bufio.NewScanner(strings.NewReader(line)), tweeter); err == nil && len(tweets) == 1 && tweets[0].Hash() == hash {
+					found = true
+					buf.WriteString(replace(tweets[0], strings.Fields(line)[0]))
+					continue
+				}
+			}
+
+			buf.WriteString(line)
+			buf.WriteString("\n")
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+
+		if !found {
+			return nil, ErrTweetNotFound
+		}
+
+		return buf.Bytes(), nil
+	})
+}
+
+// DeleteFeed removes the feed file of the given user, if any
+func DeleteFeed(path string, user *User) error {
+	feed, err := OpenFeed(path, user.Username)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	return feed.Remove()
 }
 
 func GetAllTweets(conf *Config) (Tweets, error) {
@@ -114,27 +261,65 @@ func GetAllTweets(conf *Config) (Tweets, error) {
 	var tweets Tweets
 
 	for _, info := range files {
+		// Skip temporary files of feeds being rewritten
+		if strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+
 		tweeter := Tweeter{
 			Nick: info.Name(),
-			URL:  fmt.Sprintf("%s/u/%s", strings.TrimSuffix(conf.BaseURL, "/"), info.Name()),
+			URL:  URLForUser(conf.BaseURL, info.Name()),
 		}
-		fn := filepath.Join(p, info.Name())
-		f, err := os.Open(fn)
+		feed, err := OpenFeed(conf.Data, info.Name())
 		if err != nil {
-			log.WithError(err).Warnf("error opening feed: %s", fn)
+			log.WithError(err).Warnf("error opening feed: %s", info.Name())
 			continue
 		}
-		s := bufio.NewScanner(f)
-		tweets = append(tweets, ParseFile(s, tweeter)...)
-		f.Close()
+		data, _, err := feed.Read()
+		if err != nil {
+			log.WithError(err).Warnf("error reading feed: %s", feed.Path())
+			continue
+		}
+		parsed, err := ParseFile(bufio.NewScanner(bytes.NewReader(data)), tweeter)
+		if err != nil {
+			log.WithError(err).Warnf("error parsing feed: %s", feed.Path())
+		}
+		tweets = append(tweets, parsed...)
 	}
 
 	return tweets, nil
 }
 
-func ParseFile(scanner *bufio.Scanner, tweeter Tweeter) Tweets {
+// GetUserTweets returns the tweets in the feed of a local user
+func GetUserTweets(conf *Config, username string) (Tweets, error) {
+	feed, err := OpenFeed(conf.Data, username)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _, err := feed.Read()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	tweeter := Tweeter{
+		Nick: username,
+		URL:  URLForUser(conf.BaseURL, username),
+	}
+
+	return ParseFile(bufio.NewScanner(bytes.NewReader(data)), tweeter)
+}
+
+// ParseFile parses the tweets of the feed by tweeter, returning those parsed
+// so far along with an error if it cannot be read, e.g: has a line longer
+// than MaxFeedSize
+func ParseFile(scanner *bufio.Scanner, tweeter Tweeter) (Tweets, error) {
 	var tweets Tweets
 	re := regexp.MustCompile(`^(.+?)(\s+)(.+)$`) // .+? is ungreedy
+	scanner.Buffer(nil, MaxFeedSize)
 	for scanner.Scan() {
 		line := scanner.Text()
 		if line == "" {
@@ -157,10 +342,7 @@ func ParseFile(scanner *bufio.Scanner, tweeter Tweeter) Tweets {
 				Text:    parts[3],
 			})
 	}
-	if err := scanner.Err(); err != nil {
-		panic(err)
-	}
-	return tweets
+	return tweets, scanner.Err()
 }
 
 func ParseTime(timestr string) time.Time {
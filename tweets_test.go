@@ -0,0 +1,213 @@
+package twtxt
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setupFeed(t *testing.T, feed string) (*Config, *User, string) {
+	dir, err := ioutil.TempDir("", "twtxt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &Config{Data: dir, BaseURL: "https://example.com"}
+	user := &User{Username: "alice"}
+
+	fn := filepath.Join(dir, feedsDir, "alice")
+	if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(fn, []byte(feed), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return conf, user, fn
+}
+
+// newTestStore returns a store in a temporary directory, which is also
+// returned for the test's other data, removing both when the test is done
+func newTestStore(t *testing.T) (*BitcaskStore, string) {
+	dir, err := ioutil.TempDir("", "twtxt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	db, err := newBitcaskStore(filepath.Join(dir, "twtxt.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.db.Close() })
+
+	return db, dir
+}
+
+const testFeed = "# nick = alice\n" +
+	"2020-07-20T12:00:00Z\tfirst\n" +
+	"2020-07-20T13:00:00Z\tsecond\n" +
+	"2020-07-20T14:00:00Z\tthird\n"
+
+func tweetHash(t *testing.T, conf *Config, text string) string {
+	tweets, err := GetUserTweets(conf, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tweet := range tweets {
+		if tweet.Text == text {
+			return tweet.Hash()
+		}
+	}
+	t.Fatalf("tweet %q not found", text)
+	return ""
+}
+
+func TestEditTweet(t *testing.T) {
+	conf, user, fn := setupFeed(t, testFeed)
+	defer os.RemoveAll(conf.Data)
+
+	old, edited, err := EditTweet(conf, user, tweetHash(t, conf, "second"), "second\nedited")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old.Text != "second" || edited.Text != "second edited" || !edited.Created.Equal(old.Created) {
+		t.Errorf("unexpected edit of %+v to %+v", old, edited)
+	}
+
+	data, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "# nick = alice\n" +
+		"2020-07-20T12:00:00Z\tfirst\n" +
+		"2020-07-20T13:00:00Z\tsecond edited\n" +
+		"2020-07-20T14:00:00Z\tthird\n"
+	if string(data) != expected {
+		t.Errorf("expected feed:\n%s\ngot:\n%s", expected, data)
+	}
+
+	if _, _, err := EditTweet(conf, user, "nothere", "text"); err != ErrTweetNotFound {
+		t.Errorf("expected ErrTweetNotFound got %v", err)
+	}
+}
+
+func TestDeleteTweet(t *testing.T) {
+	testCases := []struct {
+		name      string
+		tombstone bool
+		expected  string
+	}{
+		{
+			"remove", false,
+			"# nick = alice\n" +
+				"2020-07-20T12:00:00Z\tfirst\n" +
+				"2020-07-20T14:00:00Z\tthird\n",
+		},
+		{
+			"tombstone", true,
+			"# nick = alice\n" +
+				"2020-07-20T12:00:00Z\tfirst\n" +
+				"2020-07-20T13:00:00Z\t" + TombstoneText + "\n" +
+				"2020-07-20T14:00:00Z\tthird\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conf, user, fn := setupFeed(t, testFeed)
+			defer os.RemoveAll(conf.Data)
+
+			// Pretend the feed was just written to check the modification
+			// time still moves forward
+			now := time.Now().Truncate(time.Second).Add(2 * time.Second)
+			if err := os.Chtimes(fn, now, now); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := DeleteTweet(conf, user, tweetHash(t, conf, "second"), tc.tombstone); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := ioutil.ReadFile(fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tc.expected {
+				t.Errorf("expected feed:\n%s\ngot:\n%s", tc.expected, data)
+			}
+
+			stat, err := os.Stat(fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !stat.ModTime().After(now) {
+				t.Errorf("expected modification time after %s got %s", now, stat.ModTime())
+			}
+		})
+	}
+}
+
+func TestCollapseMentions(t *testing.T) {
+	text := "hi @<bob https://example.com/u/bob> and @carol"
+	if actual := CollapseMentions(text); actual != "hi @bob and @carol" {
+		t.Errorf("unexpected collapsed text %q", actual)
+	}
+}
+
+func TestExpandMentions(t *testing.T) {
+	urls := map[string]string{
+		"alice": "https://example.com/u/alice",
+		"bob":   "https://example.com/@alice/twtxt.txt",
+	}
+	resolve := func(nick string) (string, bool) {
+		url, ok := urls[nick]
+		return url, ok
+	}
+
+	testCases := []struct {
+		text     string
+		expected string
+	}{
+		{"@alice hi", "@<alice https://example.com/u/alice> hi"},
+		{"hi @alice, @bob!", "hi @<alice https://example.com/u/alice>, @<bob https://example.com/@alice/twtxt.txt>!"},
+		{"line\n@alice", "line\n@<alice https://example.com/u/alice>"},
+		{"mail bob@alice.com", "mail bob@alice.com"},
+		{"hi @alice-smith", "hi @alice-smith"},
+		{"@alice’s tweet", "@<alice https://example.com/u/alice>’s tweet"},
+		{"hi @alice…", "hi @<alice https://example.com/u/alice>…"},
+		{"hi @alice@example.org", "hi @alice@example.org"},
+		{"hi @nobody", "hi @nobody"},
+	}
+
+	for _, tc := range testCases {
+		actual := expandMentions(tc.text, resolve)
+		if actual != tc.expected {
+			t.Errorf("expanding %q\nexpected: %s\ngot:      %s", tc.text, tc.expected, actual)
+		}
+
+		// Expanded mentions are never expanded again, even when their URL
+		// contains something that looks like a mention
+		if again := expandMentions(actual, resolve); again != actual {
+			t.Errorf("expanding %q twice\nexpected: %s\ngot:      %s", tc.text, actual, again)
+		}
+	}
+}
+
+func TestParseFileLongLine(t *testing.T) {
+	feed := "2020-07-20T12:00:00Z\tfirst\n" +
+		"2020-07-20T13:00:00Z\t" + strings.Repeat("x", MaxFeedSize) + "\n"
+
+	tweets, err := ParseFile(bufio.NewScanner(strings.NewReader(feed)), Tweeter{Nick: "alice"})
+	if err != bufio.ErrTooLong {
+		t.Errorf("expected bufio.ErrTooLong got %v", err)
+	}
+	if len(tweets) != 1 || tweets[0].Text != "first" {
+		t.Errorf("expected the tweets before the long line got %v", tweets)
+	}
+}
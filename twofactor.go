@@ -0,0 +1,82 @@
+package twtxt
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	qrcode "github.com/skip2/go-qrcode"
+
+	"github.com/prologic/twtxt/totp"
+)
+
+const (
+	// RecoveryCodesCount is the number of recovery codes issued when
+	// enabling two-factor authentication
+	RecoveryCodesCount = 10
+)
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.Replace(strings.TrimSpace(code), "-", "", -1))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateRecoveryCodes returns a set of new recovery codes along with the
+// hashes that are stored on the user
+func GenerateRecoveryCodes() (codes []string, hashes []string, err error) {
+	for i := 0; i < RecoveryCodesCount; i++ {
+		buf := make([]byte, 10)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		token := strings.ToLower(base32.StdEncoding.EncodeToString(buf))
+		code := token[:5] + "-" + token[5:10]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return
+}
+
+// UseRecoveryCode checks code against the user's unused recovery codes and
+// removes it if it matches so that it cannot be used again
+func UseRecoveryCode(user *User, code string) bool {
+	hash := hashRecoveryCode(code)
+	for i, h := range user.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+			user.RecoveryCodes = append(user.RecoveryCodes[:i], user.RecoveryCodes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// CheckTOTP validates a one-time code for the user, rejecting codes that
+// have already been used, and records the time step it was used in
+func CheckTOTP(user *User, code string) bool {
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+	if !ok || step <= user.TOTPLastStep {
+		return false
+	}
+	user.TOTPLastStep = step
+	return true
+}
+
+// QRCodeDataURI renders content as a QR code PNG suitable for an <img> src
+func QRCodeDataURI(content string) (template.URL, error) {
+	png, err := qrcode.Encode(content, qrcode.Medium, 256)
+	if err != nil {
+		return "", err
+	}
+	return template.URL(fmt.Sprintf(
+		"data:image/png;base64,%s",
+		base64.StdEncoding.EncodeToString(png),
+	)), nil
+}
@@ -1,9 +1,12 @@
 package twtxt
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
-	"html/template"
-	"regexp"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/goware/urlx"
@@ -27,6 +30,21 @@ func ParseURI(uri string) (*URI, error) {
 	return nil, fmt.Errorf("invalid uri: %s", uri)
 }
 
+// GenerateRandomToken returns a random URL-safe string encoding n bytes of
+// entropy read from crypto/rand
+func GenerateRandomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// URLForUser returns the feed URL of a local user
+func URLForUser(baseURL, username string) string {
+	return fmt.Sprintf("%s/u/%s", strings.TrimSuffix(baseURL, "/"), username)
+}
+
 func NormalizeURL(url string) string {
 	if url == "" {
 		return ""
@@ -50,12 +68,41 @@ func NormalizeURL(url string) string {
 	return norm
 }
 
-// FormatMentions turns `@<nick URL>` into `<a href="URL">@nick</a>`
-func FormatMentions(text string) template.HTML {
-	re := regexp.MustCompile(`@<([^ ]+) *([^>]+)>`)
-	return template.HTML(re.ReplaceAllStringFunc(text, func(match string) string {
-		parts := re.FindStringSubmatch(match)
-		nick, url := parts[1], parts[2]
-		return fmt.Sprintf(`<a href="%s">@%s</a>`, url, nick)
-	}))
+// writeFileAtomic writes data to a temporary file next to fn and renames it
+// into place so readers never see a partially written file
+func writeFileAtomic(fn string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(fn), "."+filepath.Base(fn)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		return err
+	}
+
+	if err := os.Rename(f.Name(), fn); err != nil {
+		return err
+	}
+
+	// Sync the directory too so the rename itself survives a crash
+	dir, err := os.Open(filepath.Dir(fn))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	return dir.Sync()
 }
@@ -0,0 +1,169 @@
+package twtxt
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MinUsernameLength is the minimum length of a username
+	MinUsernameLength = 2
+
+	// MaxUsernameLength is the maximum length of a username
+	MaxUsernameLength = 32
+
+	// MinPasswordLength is the minimum length of a password
+	MinPasswordLength = 8
+
+	// MaxDisplayNameLength is the maximum length of a display name
+	MaxDisplayNameLength = 64
+
+	// MaxDescriptionLength is the maximum length of a profile description
+	MaxDescriptionLength = 280
+
+	// MaxHomepageLength is the maximum length of a homepage URL
+	MaxHomepageLength = 256
+)
+
+var (
+	ErrUsernameTaken = errors.New("username is already taken")
+
+	validUsername = regexp.MustCompile(`^[a-zA-Z0-9][_a-zA-Z0-9]*$`)
+
+	// ReservedUsernames are usernames that cannot be registered because they
+	// clash with routes or would be confused with the instance itself
+	ReservedUsernames = []string{
+		"about", "admin", "administrator", "api", "css", "delete", "discover",
+		"edit", "feed", "feeds", "follow", "help", "img", "import", "js",
+		"login", "logout", "me", "media", "mentions", "operator", "post",
+		"privacy", "register", "root", "settings", "support", "system",
+		"twtxt", "u", "unfollow", "user", "users",
+	}
+
+	commonPasswords = []string{
+		"password", "password1", "12345678", "123456789", "1234567890",
+		"qwertyuiop", "iloveyou", "11111111", "abc12345", "sunshine",
+		"princess", "football", "baseball", "welcome1", "letmein1",
+	}
+)
+
+// ValidateUsername checks that the username is of the permitted length,
+// consists only of letters, digits and underscores and is not reserved
+func ValidateUsername(username string) error {
+	if len(username) < MinUsernameLength || len(username) > MaxUsernameLength {
+		return fmt.Errorf(
+			"username must be between %d and %d characters",
+			MinUsernameLength, MaxUsernameLength,
+		)
+	}
+
+	if !validUsername.MatchString(username) {
+		return errors.New("username may only contain letters, digits and underscores and must start with a letter or digit")
+	}
+
+	for _, reserved := range ReservedUsernames {
+		if strings.EqualFold(username, reserved) {
+			return errors.New("username is reserved")
+		}
+	}
+
+	return nil
+}
+
+// ValidateEmail checks that email, if provided, is a valid bare address
+func ValidateEmail(email string) error {
+	if email == "" {
+		return nil
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || !strings.Contains(addr.Address, ".") {
+		return errors.New("email address is invalid")
+	}
+
+	return nil
+}
+
+// ValidatePassword checks that the password is long enough and not trivially
+// guessable from the username or a list of very common passwords
+func ValidatePassword(username, password string) error {
+	if len(password) < MinPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	}
+
+	if strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return errors.New("password must not contain your username")
+	}
+
+	for _, common := range commonPasswords {
+		if strings.EqualFold(password, common) {
+			return errors.New("password is too common")
+		}
+	}
+
+	return nil
+}
+
+// UsernameExists performs a case-insensitive check for an existing user
+func UsernameExists(db Store, username string) (bool, error) {
+	users, err := db.GetAllUsers()
+	if err != nil {
+		return false, err
+	}
+
+	for _, user := range users {
+		if strings.EqualFold(user.Username, username) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// ValidateDisplayName checks that the display name is a single line of at
+// most MaxDisplayNameLength characters
+func ValidateDisplayName(name string) error {
+	if utf8.RuneCountInString(name) > MaxDisplayNameLength {
+		return fmt.Errorf("display name must be at most %d characters", MaxDisplayNameLength)
+	}
+	if strings.ContainsAny(name, "\r\n") {
+		return errors.New("display name must be a single line")
+	}
+	return nil
+}
+
+// ValidateDescription checks that the description is at most
+// MaxDescriptionLength characters
+func ValidateDescription(description string) error {
+	if utf8.RuneCountInString(description) > MaxDescriptionLength {
+		return fmt.Errorf("description must be at most %d characters", MaxDescriptionLength)
+	}
+	return nil
+}
+
+// ValidateHomepage checks that the homepage, if any, is an absolute http or
+// https URL
+func ValidateHomepage(homepage string) error {
+	if homepage == "" {
+		return nil
+	}
+
+	if len(homepage) > MaxHomepageLength {
+		return fmt.Errorf("homepage must be at most %d characters", MaxHomepageLength)
+	}
+
+	u, err := url.Parse(homepage)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("homepage must be a http:// or https:// URL")
+	}
+	if strings.ContainsAny(homepage, " \t\r\n") {
+		return errors.New("homepage must not contain whitespace")
+	}
+
+	return nil
+}
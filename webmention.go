@@ -0,0 +1,333 @@
+package twtxt
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// MaxWebmentionSourceSize is the most read of a feed when verifying a
+	// webmention or discovering its endpoint
+	MaxWebmentionSourceSize = 1 << 20
+
+	// maxWebmentionVerifiers is the number of webmentions verified at once,
+	// more are turned away until some are done
+	maxWebmentionVerifiers = 10
+)
+
+var (
+	// ErrInvalidWebmention is returned when a webmention does not come from
+	// a http(s) feed or is not for the feed of a local user
+	ErrInvalidWebmention = errors.New("error: webmentions must be from a http(s) feed to the feed of a local user")
+
+	// ErrWebmentionNotFound is returned when the source of a webmention does
+	// not mention its target
+	ErrWebmentionNotFound = errors.New("error: source does not mention target")
+
+	// ErrNoWebmentionEndpoint is returned when a feed does not advertise a
+	// webmention endpoint
+	ErrNoWebmentionEndpoint = errors.New("error: feed has no webmention endpoint")
+)
+
+// webmentionClient sends webmentions, never to internal services as their
+// endpoints are given by others
+var webmentionClient = newFetchClient(15 * time.Second)
+
+var (
+	// pendingWebmentions are the webmentions being verified by their source
+	// and target, so that repeated ones are only verified once
+	pendingWebmentions sync.Map
+
+	webmentionVerifiers = make(chan struct{}, maxWebmentionVerifiers)
+)
+
+// URLForWebmention returns the webmention endpoint of the instance
+func URLForWebmention(baseURL string) string {
+	return fmt.Sprintf("%s/webmention", strings.TrimSuffix(baseURL, "/"))
+}
+
+// RemoteMentions returns the URLs of the feeds not hosted by this instance
+// mentioned in the text as `@<nick URL>`
+func RemoteMentions(conf *Config, text string) []string {
+	prefix := URLForUser(conf.BaseURL, "")
+
+	var urls []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		target, ok := safeURL(match[2])
+		if !ok || strings.HasPrefix(target, prefix) {
+			continue
+		}
+
+		key := NormalizeURL(target)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		urls = append(urls, target)
+	}
+
+	return urls
+}
+
+// webmentionEndpointFromLinks returns the target of the first Link header
+// with a rel of webmention
+func webmentionEndpointFromLinks(headers []string) string {
+	for _, header := range headers {
+		for _, link := range strings.Split(header, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+
+			for _, param := range parts[1:] {
+				kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+				if len(kv) != 2 || strings.ToLower(strings.TrimSpace(kv[0])) != "rel" {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(kv[1], `"`)) {
+					if strings.ToLower(rel) == "webmention" {
+						return target[1 : len(target)-1]
+					}
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+// fetchFeed fetches the feed at the URL reading at most
+// MaxWebmentionSourceSize of it, only from a public address
+func fetchFeed(rawurl string) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(http.MethodGet, rawurl, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", fmt.Sprintf("twtxt/%s", FullVersion()))
+
+	resp, err := feedClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("error fetching %s: %s", rawurl, resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, MaxWebmentionSourceSize))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resp, data, nil
+}
+
+// DiscoverWebmentionEndpoint returns the webmention endpoint of the feed at
+// target, advertised by either a Link header or a `# webmention = URL`
+// metadata comment
+func DiscoverWebmentionEndpoint(target string) (string, error) {
+	resp, data, err := fetchFeed(target)
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := webmentionEndpointFromLinks(resp.Header["Link"])
+	if endpoint == "" {
+		endpoint = ParseFeedMetadata(data)["webmention"]
+	}
+	if endpoint == "" {
+		return "", ErrNoWebmentionEndpoint
+	}
+
+	ref, err := url.Parse(endpoint)
+	if err != nil {
+		return "", ErrNoWebmentionEndpoint
+	}
+
+	endpoint, ok := safeURL(resp.Request.URL.ResolveReference(ref).String())
+	if !ok {
+		return "", ErrNoWebmentionEndpoint
+	}
+
+	return endpoint, nil
+}
+
+// SendWebmention notifies the feed at target that the feed at source
+// mentions it
+func SendWebmention(source, target string) error {
+	endpoint, err := DiscoverWebmentionEndpoint(target)
+	if err != nil {
+		return err
+	}
+
+	form := url.Values{"source": {source}, "target": {target}}
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", fmt.Sprintf("twtxt/%s", FullVersion()))
+
+	resp, err := webmentionClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("error sending webmention to %s: %s", endpoint, resp.Status)
+	}
+
+	return nil
+}
+
+// SendWebmentions notifies the remote feeds mentioned in the tweet, which
+// includes the feeds it replies to
+func SendWebmentions(conf *Config, tweet Tweet) {
+	for _, target := range RemoteMentions(conf, tweet.Text) {
+		err := SendWebmention(tweet.Tweeter.URL, target)
+		if err == ErrNoWebmentionEndpoint {
+			log.Debugf("no webmention endpoint for %s", target)
+		} else if err != nil {
+			log.WithError(err).Warnf("error sending webmention from %s to %s", tweet.Tweeter.URL, target)
+		}
+	}
+}
+
+// webmentionTarget returns the local user whose feed is the target of a
+// webmention
+func webmentionTarget(conf *Config, db Store, target string) (*User, error) {
+	prefix := URLForUser(conf.BaseURL, "")
+	if !strings.HasPrefix(target, prefix) {
+		return nil, ErrInvalidWebmention
+	}
+
+	username := strings.TrimPrefix(target, prefix)
+	if ValidateUsername(username) != nil {
+		return nil, ErrInvalidWebmention
+	}
+
+	user, err := db.GetUser(username)
+	if err != nil || user.Disabled {
+		return nil, ErrInvalidWebmention
+	}
+
+	return user, nil
+}
+
+// VerifyWebmention fetches the feed at source and delivers its tweets that
+// mention target, the feed of a local user, to their inbox as of now. It
+// returns the number of new mentions.
+func VerifyWebmention(conf *Config, db Store, source, target string, now time.Time) (int, error) {
+	if _, err := webmentionTarget(conf, db, target); err != nil {
+		return 0, err
+	}
+
+	source, ok := safeURL(source)
+	if !ok {
+		return 0, ErrInvalidWebmention
+	}
+
+	_, data, err := fetchFeed(source)
+	if err != nil {
+		return 0, err
+	}
+
+	nick := ParseFeedMetadata(data)["nick"]
+	if nick == "" || strings.ContainsAny(nick, " \t") {
+		u, _ := url.Parse(source)
+		nick = u.Hostname()
+	}
+
+	tweets, err := ParseFile(bufio.NewScanner(bytes.NewReader(data)), Tweeter{Nick: nick, URL: source})
+	if err != nil {
+		return 0, err
+	}
+
+	var matches Tweets
+	for _, tweet := range tweets {
+		if mentionsURL(tweet.Text, NormalizeURL(target)) {
+			matches = append(matches, tweet)
+		}
+	}
+	if len(matches) == 0 {
+		return 0, ErrWebmentionNotFound
+	}
+
+	return DeliverMentions(conf, db, matches, now)
+}
+
+// WebmentionHandler receives webmentions from other instances, verifying
+// them in the background
+func (s *Server) WebmentionHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		ip := RemoteIP(r)
+		if s.webmentionLimiter.Wait(ip) > 0 {
+			writePlain(w, http.StatusTooManyRequests, "Too Many Requests\n")
+			return
+		}
+		s.webmentionLimiter.Hit(ip)
+
+		source := r.FormValue("source")
+		target := r.FormValue("target")
+
+		if _, ok := safeURL(source); !ok || source == target {
+			writePlain(w, http.StatusBadRequest, "Bad Request\n")
+			return
+		}
+		if _, err := webmentionTarget(s.config, s.db, target); err != nil {
+			writePlain(w, http.StatusBadRequest, "Bad Request\n")
+			return
+		}
+
+		key := source + " " + target
+		if _, pending := pendingWebmentions.LoadOrStore(key, true); pending {
+			writePlain(w, http.StatusAccepted, "Accepted\n")
+			return
+		}
+
+		select {
+		case webmentionVerifiers <- struct{}{}:
+		default:
+			pendingWebmentions.Delete(key)
+			w.Header().Set("Retry-After", "60")
+			writePlain(w, http.StatusTooManyRequests, "Too Many Requests\n")
+			return
+		}
+
+		go func() {
+			defer func() {
+				// A bad source must not take the whole server down
+				if err := recover(); err != nil {
+					log.Errorf("panic verifying webmention from %s to %s: %v", source, target, err)
+				}
+				<-webmentionVerifiers
+				pendingWebmentions.Delete(key)
+			}()
+
+			n, err := VerifyWebmention(s.config, s.db, source, target, time.Now())
+			if err != nil {
+				log.WithError(err).Warnf("error verifying webmention from %s to %s", source, target)
+				return
+			}
+			log.Infof("delivered %d mentions from webmention of %s to %s", n, source, target)
+		}()
+
+		writePlain(w, http.StatusAccepted, "Accepted\n")
+	}
+}
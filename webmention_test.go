@@ -0,0 +1,116 @@
+package twtxt
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRemoteMentions(t *testing.T) {
+	conf := &Config{BaseURL: "https://example.com"}
+
+	text := "@<alice https://example.com/u/alice> @<bob https://bob.example.org/twtxt.txt> " +
+		"@<bobby http://bob.example.org/twtxt.txt> @<evil javascript:alert(1)> @<https://carol.example.org/twtxt.txt>"
+
+	expected := []string{"https://bob.example.org/twtxt.txt", "https://carol.example.org/twtxt.txt"}
+	if actual := RemoteMentions(conf, text); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v got %v", expected, actual)
+	}
+}
+
+func TestDiscoverWebmentionEndpoint(t *testing.T) {
+	defer allowPrivateAddresses()()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/link.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `<https://other.example.org/>; rel="other", </webmention>; rel="webmention"`)
+		fmt.Fprint(w, "# webmention = https://ignored.example.org/webmention\n")
+	})
+	mux.HandleFunc("/metadata.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "# nick = bob\n# webmention = https://bob.example.org/webmention\n")
+	})
+	mux.HandleFunc("/unsafe.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "# webmention = javascript:alert(1)\n")
+	})
+	mux.HandleFunc("/none.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "2020-07-20T12:00:00Z\thello\n")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	testCases := []struct {
+		path     string
+		endpoint string
+		err      error
+	}{
+		{"/link.txt", server.URL + "/webmention", nil},
+		{"/metadata.txt", "https://bob.example.org/webmention", nil},
+		{"/unsafe.txt", "", ErrNoWebmentionEndpoint},
+		{"/none.txt", "", ErrNoWebmentionEndpoint},
+	}
+
+	for _, testCase := range testCases {
+		endpoint, err := DiscoverWebmentionEndpoint(server.URL + testCase.path)
+		if endpoint != testCase.endpoint || err != testCase.err {
+			t.Errorf("%s: expected %q (%v) got %q (%v)", testCase.path, testCase.endpoint, testCase.err, endpoint, err)
+		}
+	}
+}
+
+func TestVerifyWebmention(t *testing.T) {
+	defer allowPrivateAddresses()()
+
+	db, dir := newTestStore(t)
+
+	for _, user := range []*User{{Username: "alice"}, {Username: "mallory", Disabled: true}} {
+		if err := db.SetUser(user.Username, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conf := &Config{Data: dir, BaseURL: "https://example.com"}
+	now := time.Date(2020, 7, 20, 12, 0, 0, 0, time.UTC)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/twtxt.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "# nick = bob\n"+
+			"2020-07-20T11:00:00Z\thi @<alice https://example.com/u/alice>\n"+
+			"2020-07-20T11:30:00Z\tjust talking\n")
+	})
+	mux.HandleFunc("/other.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "2020-07-20T11:00:00Z\tnothing to see here\n")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	alice := URLForUser(conf.BaseURL, "alice")
+
+	if _, err := VerifyWebmention(conf, db, server.URL+"/twtxt.txt", URLForUser(conf.BaseURL, "mallory"), now); err != ErrInvalidWebmention {
+		t.Errorf("expected ErrInvalidWebmention for a disabled user got %v", err)
+	}
+	if _, err := VerifyWebmention(conf, db, server.URL+"/twtxt.txt", "https://elsewhere.com/u/alice", now); err != ErrInvalidWebmention {
+		t.Errorf("expected ErrInvalidWebmention for a remote target got %v", err)
+	}
+	if _, err := VerifyWebmention(conf, db, server.URL+"/other.txt", alice, now); err != ErrWebmentionNotFound {
+		t.Errorf("expected ErrWebmentionNotFound got %v", err)
+	}
+
+	n, err := VerifyWebmention(conf, db, server.URL+"/twtxt.txt", alice, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 delivered mention got %d", n)
+	}
+
+	mentions, err := GetUserMentions(db, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mentions) != 1 || mentions[0].Tweet.Tweeter.Nick != "bob" || mentions[0].Tweet.Tweeter.URL != server.URL+"/twtxt.txt" {
+		t.Errorf("unexpected mentions %+v", mentions)
+	}
+}